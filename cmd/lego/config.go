@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego/pkg/bootstarp"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "config tools",
+}
+
+var checkFlags appFlags
+var checkDump bool
+
+var configCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "load config of the environment and validate it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := bootstarp.CheckConfig(checkFlags.config, checkFlags.env)
+		if err != nil {
+			return err
+		}
+		if checkDump {
+			fmt.Fprint(cmd.OutOrStdout(), c.Dump())
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "config %s env:%s ok\n", checkFlags.config, checkFlags.env)
+		return nil
+	},
+}
+
+func init() {
+	checkFlags.bind(configCheckCmd)
+	configCheckCmd.Flags().BoolVar(&checkDump, "dump", false, "print every key with its source, secrets redacted")
+	configCmd.AddCommand(configCheckCmd)
+}
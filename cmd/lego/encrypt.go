@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego/components/config"
+)
+
+var genKeyCmd = &cobra.Command{
+	Use:   "genkey",
+	Short: "generate a base64 secret key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := config.GenerateSecretKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), key)
+		return nil
+	},
+}
+
+//密钥不通过命令行参数传入, 避免出现在 shell 历史和进程列表中
+var encryptFlags struct {
+	keyFile string
+}
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [value]",
+	Short: "encrypt a config value with AES-GCM, read stdin when value is empty",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := readKey(encryptFlags.keyFile)
+		if err != nil {
+			return err
+		}
+		var value string
+		if len(args) > 0 {
+			value = args[0]
+		} else {
+			data, err := ioutil.ReadAll(bufio.NewReader(cmd.InOrStdin()))
+			if err != nil {
+				return err
+			}
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+		encrypted, err := config.EncryptValue(key, value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), encrypted)
+		return nil
+	},
+}
+
+func init() {
+	encryptCmd.Flags().StringVarP(&encryptFlags.keyFile, "key-file", "k", "", "secret key file, default $"+config.SecretKeyFileEnv)
+}
+
+//依次读取 --key-file, $LEGO_SECRET_KEY, $LEGO_SECRET_KEY_FILE
+func readKey(keyFile string) ([]byte, error) {
+	var key string
+	if len(keyFile) == 0 {
+		key = os.Getenv(config.SecretKeyEnv)
+		keyFile = os.Getenv(config.SecretKeyFileEnv)
+	}
+	if len(key) == 0 && len(keyFile) > 0 {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = string(data)
+	}
+	if len(key) == 0 {
+		return nil, errors.New(fmt.Sprintf("secret key not set, use --key-file, $%s or $%s", config.SecretKeyEnv, config.SecretKeyFileEnv))
+	}
+	return config.ParseSecretKey(key)
+}
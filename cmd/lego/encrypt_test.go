@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/config"
+)
+
+func TestEncrypt_ReadKey(t *testing.T) {
+	key, err := config.GenerateSecretKey()
+	assert.Nil(t, err)
+	os.Unsetenv(config.SecretKeyFileEnv)
+
+	//不提供 --key 参数
+	assert.Nil(t, encryptCmd.Flags().Lookup("key"))
+
+	//环境变量
+	assert.Nil(t, os.Setenv(config.SecretKeyEnv, key))
+	_, err = readKey("")
+	assert.Nil(t, err)
+	os.Unsetenv(config.SecretKeyEnv)
+	_, err = readKey("")
+	assert.NotNil(t, err)
+
+	//密钥文件
+	filename := filepath.Join(t.TempDir(), "secret.key")
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(key+"\n"), 0600))
+	_, err = readKey(filename)
+	assert.Nil(t, err)
+
+	//从标准输入读取明文
+	out := &bytes.Buffer{}
+	encryptCmd.SetIn(strings.NewReader("password\n"))
+	encryptCmd.SetOut(out)
+	encryptFlags.keyFile = filename
+	defer func() { encryptFlags.keyFile = "" }()
+	assert.Nil(t, encryptCmd.RunE(encryptCmd, nil))
+	secret, err := config.ParseSecretKey(key)
+	assert.Nil(t, err)
+	value, err := config.DecryptValue(secret, strings.TrimSpace(out.String()))
+	assert.Nil(t, err)
+	assert.Equal(t, "password", value)
+}
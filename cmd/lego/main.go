@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego"
+)
+
+//lego 命令行工具
+//usage:
+//
+//	lego new demo --module github.com/foo/demo  生成服务骨架
+//	lego run -c ./configs/config.toml -e develop 运行当前目录的服务
+//	lego config check -c ./configs/config.toml -e prod
+//	lego routes -c ./configs/config.toml         输出已注册的路由, 不启动监听
+//	lego stop -c ./configs/config.toml -e prod   向 app.pidfile 中的进程发送 SIGTERM 并等待退出
+//	lego reload -p ./indexer.pid                 发送 SIGUSR2 重新加载配置
+//	lego genkey                                  生成密钥
+//	lego encrypt -k ./secret.key value           加密配置值, 输出 enc:...
+
+var rootCmd = &cobra.Command{
+	Use:          "lego",
+	Short:        "lego - a web kit use go happily",
+	Version:      lego.Version,
+	SilenceUsage: true,
+}
+
+func main() {
+	rootCmd.AddCommand(newCmd, runCmd, configCmd, routesCmd, stopCmd, reloadCmd, genKeyCmd, encryptCmd)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+//服务运行参数, run routes config 共用
+type appFlags struct {
+	config string
+	env    string
+}
+
+func (f *appFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.config, "config", "c", "./configs/config.toml", "config file")
+	cmd.Flags().StringVarP(&f.env, "env", "e", "develop", "environment: develop test release prod")
+}
+
+//传给服务的参数
+func (f *appFlags) args() []string {
+	return []string{"-c", f.config, "-e", f.env}
+}
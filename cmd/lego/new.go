@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego"
+)
+
+var newFlags struct {
+	module string
+	dir    string
+}
+
+var newCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "scaffold a service skeleton",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		module := newFlags.module
+		if len(module) == 0 {
+			module = name
+		}
+		dir := newFlags.dir
+		if len(dir) == 0 {
+			dir = name
+		}
+		if err := scaffold(dir, &project{Name: name, Module: module, Version: lego.Version}); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "service %s created in %s\n", name, dir)
+		return nil
+	},
+}
+
+func init() {
+	newCmd.Flags().StringVarP(&newFlags.module, "module", "m", "", "go module path, default name")
+	newCmd.Flags().StringVarP(&newFlags.dir, "dir", "d", "", "output dir, default name")
+}
+
+//模板参数
+type project struct {
+	Name    string
+	Module  string
+	Version string
+}
+
+//生成服务骨架, 目录已存在文件时不覆盖
+func scaffold(dir string, p *project) error {
+	for filename := range templates {
+		path := filepath.Join(dir, filename)
+		if _, err := os.Stat(path); err == nil {
+			return errors.New(fmt.Sprintf("file already exists:%s", path))
+		}
+	}
+	for filename, content := range templates {
+		path := filepath.Join(dir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		t, err := template.New(filename).Parse(content)
+		if err != nil {
+			return errors.New(fmt.Sprintf("parse template:%s error:%s", filename, err.Error()))
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		err = t.Execute(f, p)
+		_ = f.Close()
+		if err != nil {
+			return errors.New(fmt.Sprintf("render template:%s error:%s", filename, err.Error()))
+		}
+	}
+	return nil
+}
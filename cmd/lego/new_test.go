@@ -0,0 +1,39 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/pkg/bootstarp"
+)
+
+func TestScaffold(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, scaffold(dir, &project{Name: "demo", Module: "example.com/demo", Version: "0.1.0"}))
+
+	for filename := range templates {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filename))
+		assert.Nil(t, err)
+		if strings.HasSuffix(filename, ".go") {
+			_, err := parser.ParseFile(token.NewFileSet(), filename, data, 0)
+			assert.Nil(t, err, filename)
+		}
+	}
+	mod, _ := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	assert.True(t, strings.HasPrefix(string(mod), "module example.com/demo"))
+
+	//生成的配置可以通过校验
+	for _, env := range []string{"develop", "prod"} {
+		_, err := bootstarp.CheckConfig(filepath.Join(dir, "configs", "config.toml"), env)
+		assert.Nil(t, err, env)
+	}
+
+	//不覆盖已有文件
+	assert.NotNil(t, scaffold(dir, &project{Name: "demo", Module: "demo"}))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego/pkg/bootstarp"
+)
+
+var runFlags appFlags
+
+var runCmd = &cobra.Command{
+	Use:   "run [dir]",
+	Short: "go run the service in dir, default current dir",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return goRun(dir(args), runFlags.args(), nil)
+	},
+}
+
+var routesFlags appFlags
+
+var routesCmd = &cobra.Command{
+	Use:   "routes [dir]",
+	Short: "print the registered routes of the service in dir without starting listeners",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return goRun(dir(args), routesFlags.args(), []string{bootstarp.RoutesEnv + "=1"})
+	},
+}
+
+func init() {
+	runFlags.bind(runCmd)
+	routesFlags.bind(routesCmd)
+}
+
+func dir(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return "."
+}
+
+//在服务目录执行 go run . <args>
+func goRun(dir string, args []string, env []string) error {
+	c := exec.Command("go", append([]string{"run", "."}, args...)...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), env...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jeevi-cao/lego/components/config"
+	"github.com/jeevi-cao/lego/components/pidfile"
+)
+
+//pid文件参数, 未指定 --pidfile 时读取配置中的 app.pidfile
+type pidFlags struct {
+	appFlags
+	pidfile string
+}
+
+func (f *pidFlags) bind(cmd *cobra.Command) {
+	f.appFlags.bind(cmd)
+	cmd.Flags().StringVarP(&f.pidfile, "pidfile", "p", "", "pid file, default app.pidfile of config")
+}
+
+func (f *pidFlags) path() (string, error) {
+	if len(f.pidfile) > 0 {
+		return f.pidfile, nil
+	}
+	c, err := config.NewConfigWithSetting(config.Setting{Filename: f.config, Env: f.env})
+	if err != nil {
+		return "", err
+	}
+	path := c.GetHandler().GetString("app.pidfile")
+	if len(path) == 0 {
+		return "", errors.New(fmt.Sprintf("app.pidfile not set in config:%s", f.config))
+	}
+	return path, nil
+}
+
+var stopFlags pidFlags
+var stopWait time.Duration
+
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "send SIGTERM to the process in pid file and wait for it to exit",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := stopFlags.path()
+		if err != nil {
+			return err
+		}
+		pid, err := pidfile.Signal(path, syscall.SIGTERM)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "stopping pid:%d\n", pid)
+		deadline := time.Now().Add(stopWait)
+		for pidfile.IsRunning(pid) {
+			if time.Now().After(deadline) {
+				return errors.New(fmt.Sprintf("pid:%d still running after %s", pid, stopWait))
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "pid:%d stopped\n", pid)
+		return nil
+	},
+}
+
+var reloadFlags pidFlags
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "send SIGUSR2 to the process in pid file to reload config",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := reloadFlags.path()
+		if err != nil {
+			return err
+		}
+		pid, err := pidfile.Signal(path, syscall.SIGUSR2)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "reload signal sent to pid:%d\n", pid)
+		return nil
+	},
+}
+
+func init() {
+	stopFlags.bind(stopCmd)
+	stopCmd.Flags().DurationVarP(&stopWait, "wait", "w", 30*time.Second, "max wait time for the process to exit")
+	reloadFlags.bind(reloadCmd)
+}
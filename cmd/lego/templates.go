@@ -0,0 +1,151 @@
+package main
+
+//服务骨架模板
+var templates = map[string]string{
+	"go.mod":                      goModTemplate,
+	"main.go":                     mainTemplate,
+	"router.go":                   routerTemplate,
+	"crontab.go":                  crontabTemplate,
+	"configs/config.toml":         configTemplate,
+	"configs/config.develop.toml": configDevelopTemplate,
+	"configs/config.prod.toml":    configProdTemplate,
+}
+
+const goModTemplate = `module {{.Module}}
+
+go 1.14
+
+require github.com/jeevi-cao/lego v{{.Version}}
+`
+
+const mainTemplate = `package main
+
+import (
+	"os"
+
+	"github.com/jeevi-cao/lego/pkg/bootstarp"
+)
+
+//./{{.Name}} -c ./configs/config.toml -e develop
+func main() {
+	os.Exit(bootstarp.Main(
+		bootstarp.WithRoutes(Routes),
+		bootstarp.WithCrontab(Tasks),
+	))
+}
+`
+
+const routerTemplate = `package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//注册路由
+func Routes(engine *gin.Engine) {
+	engine.GET("/ping", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	})
+}
+`
+
+const crontabTemplate = `package main
+
+import (
+	"context"
+
+	"github.com/jeevi-cao/lego/components/crontab"
+	"github.com/jeevi-cao/lego/components/log"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//注册定时任务, 包装的任务在关闭时等待执行完成
+func Tasks(scheduler crontab.Scheduler) {
+	cron, _ := app.App.GetCrontab()
+	_, _ = scheduler.Every(1).Minute().Do(cron.JobFunc("tick", func(ctx context.Context) {
+		log.WithContext(ctx, app.App.GetLogger("")).Info("{{.Name}} crontab running")
+	}))
+}
+`
+
+const configTemplate = `# @see  https://github.com/toml-lang/toml
+[app]
+name = "{{.Name}}"
+time_zone = "Asia/Shanghai"
+pidfile = "./{{.Name}}.pid"
+
+[httpserver]
+    http_host = "0.0.0.0"
+    http_port = 8080
+    enable_https = false
+    middleware = ["cors", "requestid"]
+
+[log]
+    path = "./logs/"
+    filename = "app.log"
+    errfilename = "error.log"
+    format = "json"
+    level = "info"
+    split = ".%Y%m%d%H"
+    lifetime = 240
+    rotation = 24
+    # 按大小切割(MB), 压缩已切割文件, 保留文件数量及总大小上限(MB), 0 不限制
+    rotation_size = 0
+    compress = false
+    max_backups = 0
+    max_total_size = 0
+    # 异步写入, 队列满时策略 block drop_oldest drop_newest, 刷新间隔单位毫秒
+    async = false
+    async_queue_size = 1024
+    async_policy = "block"
+    async_flush_interval = 1000
+    # 发送到 kafka, 批量间隔单位毫秒, 发送失败时写入 fallback_file
+    [log.kafka]
+        enable = false
+        hosts = "127.0.0.1:9092"
+        topic = "app_log"
+        levels = ["info", "warn", "error"]
+        batch_size = 100
+        flush_interval = 1000
+        fallback_file = "./logs/app.log.kafka"
+    # 发送到 syslog, network: udp tcp unix unixgram, protocol: rfc5424 rfc3164, 发送队列满时丢弃
+    [log.syslog]
+        enable = false
+        network = "udp"
+        address = "127.0.0.1:514"
+        protocol = "rfc5424"
+        facility = "local0"
+        queue_size = 1024
+    # 敏感信息脱敏, keys 字段名(支持 * 通配), patterns 值正则或内置 mobile email bearer, mode: full partial hash
+    [log.redact]
+        enable = false
+        keys = ["password", "*token*", "authorization"]
+        patterns = ["mobile", "email", "bearer"]
+        mode = "partial"
+
+[crontab]
+    enable = true
+
+[health]
+    enable = true
+
+[shutdown]
+    #关闭总超时 单位:秒
+    timeout = 30
+    #关闭前保持未就绪的时间 单位:秒
+    pre_stop_delay = 0
+`
+
+const configDevelopTemplate = `# develop 环境覆盖配置, 与 config.toml 合并
+[log]
+    path = ""
+    level = "debug"
+    format = "text"
+`
+
+const configProdTemplate = `# prod 环境覆盖配置, 与 config.toml 合并
+[log]
+    level = "warn"
+`
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jeevi-cao/lego/components/validation"
+)
+
+//类型绑定与校验
+//usage:
+//
+//	type MongoSetting struct {
+//		Hosts       string `mapstructure:"hosts" valid:"Required"`
+//		MaxPoolSize uint64 `mapstructure:"max_pool_size" valid:"Range(1,1000)"`
+//	}
+//	var setting MongoSetting
+//	if err := cf.Bind("mongo.instance.db1", &setting); err != nil {
+//		//err 为 BindErrors, 包含所有不合法配置项的完整路径
+//		//invalid config: mongo.instance.db1.hosts: Hosts Can not be empty
+//	}
+
+const mapstructureTag = "mapstructure"
+
+//配置项错误
+type FieldError struct {
+	//完整配置路径
+	Key     string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+//所有不合法的配置项
+type BindErrors []*FieldError
+
+func (e BindErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+//没有错误时返回nil
+func (e BindErrors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+//将key对应的配置节点解析到结构体并按 valid 标签校验, key 为空时解析全部配置
+//字段名称使用 mapstructure 标签, 未设置时使用小写字段名
+func (c *Config) Bind(key string, out interface{}) error {
+	v := c.GetHandler()
+	var err error
+	if len(key) == 0 {
+		err = v.Unmarshal(out)
+	} else {
+		err = v.UnmarshalKey(key, out)
+	}
+	if err != nil {
+		return BindErrors{{Key: key, Message: err.Error()}}
+	}
+	errs := BindErrors{}
+	if err := validate(key, reflect.ValueOf(out), &errs); err != nil {
+		return BindErrors{{Key: key, Message: err.Error()}}
+	}
+	return errs.ErrorOrNil()
+}
+
+//结构体对应的所有配置项, 用于 Setting.EnvKeys
+//嵌套结构体展开为子配置项, map 及 slice 作为单个配置项
+func StructKeys(key string, out interface{}) []string {
+	t := reflect.TypeOf(out)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		sub := key
+		if !isSquash(f) {
+			sub = joinPath(key, fieldName(f))
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft.PkgPath() != "time" {
+			keys = append(keys, StructKeys(sub, reflect.New(ft).Interface())...)
+			continue
+		}
+		keys = append(keys, sub)
+	}
+	return keys
+}
+
+//递归校验结构体, 结构体指针, map 与 slice 中的结构体
+func validate(path string, value reflect.Value, errs *BindErrors) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return validateStruct(path, value, errs)
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := validate(joinPath(path, fmt.Sprint(k.Interface())), value.MapIndex(k), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := validate(fmt.Sprintf("%s[%d]", path, i), value.Index(i), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStruct(path string, value reflect.Value, errs *BindErrors) error {
+	t := value.Type()
+	//校验当前结构体字段, 需要可寻址以支持 ValidFormer
+	ptr := reflect.New(t)
+	ptr.Elem().Set(value)
+	valid := validation.Validation{}
+	if _, err := valid.Valid(ptr.Interface()); err != nil {
+		return err
+	}
+	for _, e := range valid.Errors {
+		key := path
+		if len(e.Field) > 0 {
+			if f, ok := t.FieldByName(e.Field); ok {
+				key = joinPath(path, fieldName(f))
+			} else {
+				key = joinPath(path, strings.ToLower(e.Field))
+			}
+		}
+		*errs = append(*errs, &FieldError{Key: key, Message: e.Message})
+	}
+
+	//递归校验子节点
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		sub := path
+		if !isSquash(f) {
+			sub = joinPath(path, fieldName(f))
+		}
+		if err := validate(sub, value.Field(i), errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//配置中的字段名称
+func fieldName(f reflect.StructField) string {
+	tag := f.Tag.Get(mapstructureTag)
+	if name := strings.Split(tag, ",")[0]; len(name) > 0 {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+//嵌入结构体 mapstructure:",squash" 与父节点同级
+func isSquash(f reflect.StructField) bool {
+	for _, opt := range strings.Split(f.Tag.Get(mapstructureTag), ",")[1:] {
+		if opt == "squash" {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path string, name string) string {
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/validation"
+)
+
+type testInstance struct {
+	Hosts       string `mapstructure:"hosts" valid:"Required"`
+	Uri         string `mapstructure:"uri"`
+	MaxPoolSize int    `mapstructure:"max_pool_size" valid:"Range(1,1000)"`
+}
+
+type testSection struct {
+	Type     string                   `mapstructure:"type"`
+	Timeout  int                      `mapstructure:"timeout" valid:"Min(1)"`
+	Instance map[string]*testInstance `mapstructure:"instance"`
+}
+
+type testCross struct {
+	Hosts string `mapstructure:"hosts"`
+	Uri   string `mapstructure:"uri"`
+}
+
+func (t *testCross) Valid(v *validation.Validation) {
+	if len(t.Hosts) == 0 && len(t.Uri) == 0 {
+		v.SetError("Hosts", "hosts or uri required")
+	}
+}
+
+const bindData = `
+[mongo]
+type = "multi"
+timeout = 0
+[mongo.instance.db1]
+hosts = "127.0.0.1:27017"
+max_pool_size = 100
+[mongo.instance.db2]
+max_pool_size = 2000
+[cross]
+`
+
+func TestConfig_Bind(t *testing.T) {
+	c, err := NewConfigData("toml", []byte(bindData))
+	assert.Nil(t, err)
+
+	var section testSection
+	err = c.Bind("mongo", &section)
+	assert.Equal(t, "127.0.0.1:27017", section.Instance["db1"].Hosts)
+	assert.Equal(t, 100, section.Instance["db1"].MaxPoolSize)
+
+	errs, ok := err.(BindErrors)
+	assert.True(t, ok, "error need BindErrors")
+	keys := make([]string, 0)
+	for _, e := range errs {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{"mongo.timeout", "mongo.instance.db2.hosts", "mongo.instance.db2.max_pool_size"}, keys)
+
+	var instance testInstance
+	assert.Nil(t, c.Bind("mongo.instance.db1", &instance))
+
+	var cross testCross
+	err = c.Bind("cross", &cross)
+	assert.NotNil(t, err)
+	assert.Equal(t, "cross.hosts", err.(BindErrors)[0].Key)
+}
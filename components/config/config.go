@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -19,6 +24,31 @@ import (
 //
 //	}
 //	cf.Handler.Get("log")
+//
+//	if use environment layered config like this:
+//	cf, err := NewConfigWithSetting(Setting{
+//		Filename:  "./configs/config.toml",
+//		Env:       "develop",
+//		EnvPrefix: "LEGO",
+//	})
+//	加载顺序, 后者覆盖前者:
+//	1. ./configs/config.toml
+//	2. ./configs/config.develop.toml  存在时合并
+//	3. 远程配置 AddRemote 添加的配置源, 按添加顺序合并
+//	4. 环境变量 LEGO_MONGO_INSTANCE_DB1_HOSTS 覆盖 mongo.instance.db1.hosts
+//	   文件中不存在的配置项需加入 EnvKeys 才可通过环境变量设置, StructKeys 获取结构体的配置项
+//	cf.Source("mongo.instance.db1.hosts") 查看最终取值来源
+//	配置值支持 ${ENV} file:/path enc:... 密钥引用, 见 secret.go
+//
+//	if use hot reload like this:
+//	cf.OnChange("log", func(old, new map[string]interface{}) {
+//		//old new 只包含 log 下发生变化的配置项
+//	})
+//	cf.AddValidator(func(c *Config) error {
+//		//返回错误时拒绝本次加载, 保留原配置
+//	})
+//	cf.WatchReConfig()
+//	defer cf.Close()
 
 const (
 	//配置类型
@@ -26,11 +56,41 @@ const (
 	TypeData = "data"
 )
 
+//来源前缀
+const (
+	SourceFile   = "file:"
+	SourceEnv    = "env:"
+	SourceData   = "data"
+	SourceRemote = "remote:"
+)
+
 //图片配置信息
 type Config struct {
 	Setting Setting
-	//句柄
+	//句柄, 重新加载时会替换, 并发场景使用GetHandler
 	Handler *viper.Viper
+
+	//每个key的最终来源
+	sources map[string]string
+	//包含密钥引用的key
+	secrets map[string]bool
+	mutex   sync.RWMutex
+
+	//变更订阅 重新加载校验
+	subscribers  []*subscriber
+	validators   []Validator
+	errorHandler func(err error)
+	hookMutex    sync.Mutex
+	//串行重新加载
+	reloadMutex sync.Mutex
+	//远程配置源
+	remotes []*remote
+	//文件监听
+	watcher    *fsnotify.Watcher
+	watchMutex sync.Mutex
+	//防抖加载
+	reloadTimer *time.Timer
+	timerMutex  sync.Mutex
 }
 
 //配置设置
@@ -41,33 +101,47 @@ type Setting struct {
 	Format string
 	//文件地址
 	Filename string
+	//环境名称, 非空时合并 <name>.<env>.<ext> 覆盖文件
+	Env string
+	//环境变量前缀, 非空时 <PREFIX>_<KEY> 环境变量覆盖已有配置项及 EnvKeys
+	EnvPrefix string
+	//文件中不存在时也可通过环境变量设置的配置项
+	EnvKeys []string
+	//文件变化后等待的时间, 期间的多次变化只加载一次, 默认 DefaultDebounce
+	Debounce time.Duration
+	//解密 enc: 配置值的密钥, base64编码, 为空时读取 SecretKeyFile 及环境变量
+	SecretKey     string
+	SecretKeyFile string
 }
 
 //解析配置文件
 // t  JSON, TOML, YAML, HCL, INI 文件类型
 // filename 文件
 func NewConfig(filename string) (*Config, error) {
-	v := viper.New()
-	v.SetConfigFile(filename)
-	err := v.ReadInConfig()
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("new config error! filename:%s error:%s", filename, err.Error()))
-	}
+	return NewConfigWithSetting(Setting{Filename: filename})
+}
 
-	c := new(Config)
-	setting := Setting{}
+//按环境分层解析配置文件
+func NewConfigWithSetting(setting Setting) (*Config, error) {
 	//读取文件拓展名
-	if ext := filepath.Ext(filename); len(ext) > 0 {
+	if ext := filepath.Ext(setting.Filename); len(ext) > 0 {
 		setting.Format = ext[1:]
 	}
-	setting.Filename = filename
 	setting.Type = TypeFile
+
+	c := new(Config)
 	c.Setting = setting
+	v, sources, secrets, err := c.load()
+	if err != nil {
+		return nil, err
+	}
 	c.Handler = v
+	c.sources = sources
+	c.secrets = secrets
 	return c, nil
 }
 
-//解析配置数据
+//解析配置数据, 解析密钥引用
 func NewConfigData(format string, data []byte) (*Config, error) {
 	v := viper.New()
 	v.SetConfigType(format)
@@ -80,17 +154,212 @@ func NewConfigData(format string, data []byte) (*Config, error) {
 	setting.Type = TypeData
 	setting.Format = format
 	c.Setting = setting
+	v, secrets, err := resolveSecrets(v, setting)
+	if err != nil {
+		return nil, err
+	}
 	c.Handler = v
+	c.secrets = secrets
+	c.sources = make(map[string]string)
+	for _, key := range v.AllKeys() {
+		c.sources[key] = SourceData
+	}
 
 	return c, nil
 }
 
-//监听配置变化从新读取
-func (c *Config) WatchReConfig() {
-	c.Handler.WatchConfig()
-	c.Handler.OnConfigChange(func(in fsnotify.Event) {
-		if in.Op.String() == "CREATE" {
-			_ = c.Handler.ReadInConfig()
+//解析配置数据为map, 不解析密钥引用, 用于远程配置源
+func ParseData(format string, data []byte) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewBuffer(data)); err != nil {
+		return nil, errors.New(fmt.Sprintf("parse config data fail err: %s", err.Error()))
+	}
+	return v.AllSettings(), nil
+}
+
+//按 基础文件 -> 环境覆盖文件 -> 远程配置 -> 环境变量 顺序加载, 最后解析密钥引用
+func (c *Config) load() (*viper.Viper, map[string]string, map[string]bool, error) {
+	filename := c.Setting.Filename
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, nil, errors.New(fmt.Sprintf("new config error! filename:%s error:%s", filename, err.Error()))
+	}
+	sources := make(map[string]string)
+	for _, key := range v.AllKeys() {
+		sources[key] = SourceFile + filename
+	}
+
+	//环境覆盖文件
+	if overlay := c.OverlayFilename(); len(overlay) > 0 {
+		if _, err := os.Stat(overlay); err == nil {
+			o := viper.New()
+			o.SetConfigFile(overlay)
+			if err := o.ReadInConfig(); err != nil {
+				return nil, nil, nil, errors.New(fmt.Sprintf("new config error! filename:%s error:%s", overlay, err.Error()))
+			}
+			if err := v.MergeConfigMap(o.AllSettings()); err != nil {
+				return nil, nil, nil, errors.New(fmt.Sprintf("merge config error! filename:%s error:%s", overlay, err.Error()))
+			}
+			for _, key := range o.AllKeys() {
+				sources[key] = SourceFile + overlay
+			}
+		}
+	}
+
+	//远程配置
+	for _, r := range c.getRemotes() {
+		values, source, err := r.load()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := v.MergeConfigMap(values); err != nil {
+			return nil, nil, nil, errors.New(fmt.Sprintf("merge remote config error! remote:%s error:%s", r.provider.Name(), err.Error()))
+		}
+		o := viper.New()
+		_ = o.MergeConfigMap(values)
+		for _, key := range o.AllKeys() {
+			sources[key] = source
+		}
+	}
+
+	//环境变量覆盖, 写入配置层而不是 Set, 避免按父节点读取时丢失同级的其它配置项
+	if len(c.Setting.EnvPrefix) > 0 {
+		settings := v.AllSettings()
+		overridden := false
+		for _, key := range append(v.AllKeys(), c.Setting.EnvKeys...) {
+			key = strings.ToLower(key)
+			name := EnvName(c.Setting.EnvPrefix, key)
+			if value, ok := os.LookupEnv(name); ok {
+				setPath(settings, key, value)
+				sources[key] = SourceEnv + name
+				overridden = true
+			}
+		}
+		if overridden {
+			var err error
+			if v, err = rebuild(v, settings, c.Setting.Format); err != nil {
+				return nil, nil, nil, errors.New(fmt.Sprintf("merge env config error:%s", err.Error()))
+			}
+		}
+	}
+	v, secrets, err := resolveSecrets(v, c.Setting)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return v, sources, secrets, nil
+}
+
+//环境覆盖文件地址 config.toml => config.develop.toml, 未设置环境返回空
+func (c *Config) OverlayFilename() string {
+	if len(c.Setting.Env) == 0 || len(c.Setting.Filename) == 0 {
+		return ""
+	}
+	ext := filepath.Ext(c.Setting.Filename)
+	return strings.TrimSuffix(c.Setting.Filename, ext) + "." + c.Setting.Env + ext
+}
+
+//按完整配置重建, 保留配置文件及格式
+func rebuild(v *viper.Viper, settings map[string]interface{}, format string) (*viper.Viper, error) {
+	n := viper.New()
+	if filename := v.ConfigFileUsed(); len(filename) > 0 {
+		n.SetConfigFile(filename)
+	}
+	if len(format) > 0 {
+		n.SetConfigType(format)
+	}
+	if err := n.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+//按路径写入嵌套map
+func setPath(m map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := m[part].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[part] = sub
 		}
-	})
+		m = sub
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+//配置项对应的环境变量名称 mongo.instance.db1.hosts => LEGO_MONGO_INSTANCE_DB1_HOSTS
+func EnvName(prefix string, key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+//重新加载所有配置层, 解析或校验失败时保留原配置
+//成功后按变更的配置项通知订阅者
+func (c *Config) Reload() error {
+	if c.Setting.Type != TypeFile {
+		return errors.New("only file config can reload")
+	}
+	defer c.reloadMutex.Unlock()
+	c.reloadMutex.Lock()
+
+	v, sources, secrets, err := c.load()
+	if err != nil {
+		return err
+	}
+	//校验新配置
+	candidate := &Config{Setting: c.Setting, Handler: v, sources: sources, secrets: secrets}
+	for _, f := range c.getValidators() {
+		if err := f(candidate); err != nil {
+			return errors.New(fmt.Sprintf("reload config rejected, keep old config error:%s", err.Error()))
+		}
+	}
+	c.mutex.Lock()
+	old := c.Handler
+	c.Handler = v
+	c.sources = sources
+	c.secrets = secrets
+	c.mutex.Unlock()
+
+	c.notify(old, v)
+	return nil
+}
+
+//当前句柄
+func (c *Config) GetHandler() *viper.Viper {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	return c.Handler
+}
+
+//配置项最终取值来源, file:<filename> env:<NAME> 或 data, 不存在返回空
+func (c *Config) Source(key string) string {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	return c.sources[strings.ToLower(key)]
+}
+
+//所有配置项的来源
+func (c *Config) Sources() map[string]string {
+	defer c.mutex.RUnlock()
+	c.mutex.RLock()
+	sources := make(map[string]string, len(c.sources))
+	for key, source := range c.sources {
+		sources[key] = source
+	}
+	return sources
+}
+
+//按key排序输出 key = value (source), 用于调试, 敏感配置项隐藏
+func (c *Config) Dump() string {
+	v := c.GetHandler()
+	sources := c.Sources()
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	b := &bytes.Buffer{}
+	for _, key := range keys {
+		b.WriteString(fmt.Sprintf("%s = %v (%s)\n", key, c.Redact(key, v.Get(key)), sources[key]))
+	}
+	return b.String()
 }
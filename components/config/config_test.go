@@ -2,8 +2,12 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func initConfiguration(t *testing.T) *Config {
@@ -26,3 +30,97 @@ func Test_GetLog(t *testing.T) {
 	t.Log(fmt.Sprintf("%v", v))
 
 }
+
+//写入配置文件
+func writeFile(t *testing.T, filename string, content string) {
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal("write file error:", err)
+	}
+}
+
+func TestNewConfigWithSetting(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.toml")
+	writeFile(t, filename, `
+[app]
+name = "base"
+[mongo.instance.db1]
+hosts = "127.0.0.1:27017"
+max_pool_size = 100
+`)
+	writeFile(t, filepath.Join(dir, "config.develop.toml"), `
+[app]
+name = "develop"
+`)
+	os.Setenv("LEGO_MONGO_INSTANCE_DB1_MAX_POOL_SIZE", "10")
+	defer os.Unsetenv("LEGO_MONGO_INSTANCE_DB1_MAX_POOL_SIZE")
+
+	c, err := NewConfigWithSetting(Setting{Filename: filename, Env: "develop", EnvPrefix: "lego"})
+	assert.Nil(t, err)
+	assert.Equal(t, "develop", c.Handler.GetString("app.name"))
+	assert.Equal(t, 10, c.Handler.GetInt("mongo.instance.db1.max_pool_size"))
+	assert.Equal(t, "127.0.0.1:27017", c.Handler.GetString("mongo.instance.db1.hosts"))
+
+	assert.Equal(t, SourceFile+filepath.Join(dir, "config.develop.toml"), c.Source("app.name"))
+	assert.Equal(t, SourceEnv+"LEGO_MONGO_INSTANCE_DB1_MAX_POOL_SIZE", c.Source("mongo.instance.db1.max_pool_size"))
+	assert.Equal(t, SourceFile+filename, c.Source("mongo.instance.db1.hosts"))
+	assert.Contains(t, c.Dump(), "app.name = develop")
+
+	//没有覆盖文件的环境
+	c, err = NewConfigWithSetting(Setting{Filename: filename, Env: "prod"})
+	assert.Nil(t, err)
+	assert.Equal(t, "base", c.Handler.GetString("app.name"))
+	assert.Equal(t, 100, c.Handler.GetInt("mongo.instance.db1.max_pool_size"))
+}
+
+func TestConfig_EnvKeys(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, filename, "[log]\npath = \"./logs\"\n")
+	os.Setenv("LEGO_LOG_LEVEL", "debug")
+	os.Setenv("LEGO_MONGO_HOSTS", "127.0.0.1:27017")
+	defer os.Unsetenv("LEGO_LOG_LEVEL")
+	defer os.Unsetenv("LEGO_MONGO_HOSTS")
+
+	var setting struct {
+		Path  string `mapstructure:"path"`
+		Level string `mapstructure:"level"`
+	}
+	keys := append(StructKeys("log", &setting), "mongo.hosts")
+	assert.Equal(t, []string{"log.path", "log.level", "mongo.hosts"}, keys)
+
+	//未注册的配置项不覆盖
+	c, err := NewConfigWithSetting(Setting{Filename: filename, EnvPrefix: "lego"})
+	assert.Nil(t, err)
+	assert.False(t, c.GetHandler().IsSet("log.level"))
+
+	c, err = NewConfigWithSetting(Setting{Filename: filename, EnvPrefix: "lego", EnvKeys: keys})
+	assert.Nil(t, err)
+	assert.Nil(t, c.Bind("log", &setting))
+	assert.Equal(t, "./logs", setting.Path, "keep the other keys of the node")
+	assert.Equal(t, "debug", setting.Level)
+	assert.True(t, c.GetHandler().IsSet("mongo"))
+	assert.Equal(t, SourceEnv+"LEGO_MONGO_HOSTS", c.Source("mongo.hosts"))
+
+	//重新加载时保留
+	assert.Nil(t, c.Reload())
+	assert.Equal(t, "127.0.0.1:27017", c.GetHandler().GetString("mongo.hosts"))
+}
+
+func TestConfig_Reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, filename, "[app]\nname = \"v1\"\n")
+	c, err := NewConfig(filename)
+	assert.Nil(t, err)
+
+	writeFile(t, filename, "[app]\nname = \"v2\"\n")
+	assert.Nil(t, c.Reload())
+	assert.Equal(t, "v2", c.GetHandler().GetString("app.name"))
+
+	writeFile(t, filename, "[app\nname = ")
+	assert.NotNil(t, c.Reload(), "invalid file need error")
+	assert.Equal(t, "v2", c.GetHandler().GetString("app.name"), "keep old config")
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "LEGO_MONGO_INSTANCE_DB1_HOSTS", EnvName("LEGO", "mongo.instance.db1.hosts"))
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//远程配置源
+//usage:
+//
+//	p := zookeeper.NewConfigProvider(zb, "/lego/config", "toml")
+//	if err := cf.AddRemote(p, "./runtime/remote_config.json"); err != nil {
+//		//远程不可用且没有本地缓存
+//	}
+//	远程配置合并在本地文件之上, 环境变量之下
+//	远程不可用时使用最近一次成功加载时写入的本地缓存
+//	远程变化时按防抖时间重新加载, 校验与订阅与文件加载相同
+type Provider interface {
+	//配置源名称, 用于记录来源
+	Name() string
+	//读取远程配置
+	Load() (map[string]interface{}, error)
+	//监听远程变化, 变化时调用onChange, 非阻塞
+	Watch(onChange func()) error
+	//停止监听
+	Close() error
+}
+
+type remote struct {
+	provider Provider
+	//本地缓存文件
+	cacheFile string
+}
+
+//读取远程配置, 成功时写入缓存, 失败时读取缓存
+func (r *remote) load() (map[string]interface{}, string, error) {
+	source := SourceRemote + r.provider.Name()
+	values, err := r.provider.Load()
+	if err == nil {
+		if len(r.cacheFile) > 0 {
+			if e := writeCache(r.cacheFile, values); e != nil {
+				return nil, "", errors.New(fmt.Sprintf("remote config:%s write cache error:%s", r.provider.Name(), e.Error()))
+			}
+		}
+		return values, source, nil
+	}
+	if len(r.cacheFile) == 0 {
+		return nil, "", errors.New(fmt.Sprintf("remote config:%s load error:%s", r.provider.Name(), err.Error()))
+	}
+	cached, e := readCache(r.cacheFile)
+	if e != nil {
+		return nil, "", errors.New(fmt.Sprintf("remote config:%s load error:%s, cache error:%s", r.provider.Name(), err.Error(), e.Error()))
+	}
+	return cached, source + " (cache)", nil
+}
+
+func writeCache(filename string, values map[string]interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	//先写临时文件再重命名, 避免中断时缓存损坏
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func readCache(filename string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+//添加远程配置源并立即重新加载, cacheFile 为空时不使用本地缓存
+//远程与缓存都不可用时返回错误, 不添加该配置源
+func (c *Config) AddRemote(p Provider, cacheFile string) error {
+	if c.Setting.Type != TypeFile {
+		return errors.New("only file config can add remote")
+	}
+	r := &remote{provider: p, cacheFile: cacheFile}
+	c.hookMutex.Lock()
+	c.remotes = append(c.remotes, r)
+	c.hookMutex.Unlock()
+
+	if err := c.Reload(); err != nil {
+		c.removeRemote(r)
+		return err
+	}
+	if err := p.Watch(c.scheduleReload); err != nil {
+		return errors.New(fmt.Sprintf("remote config:%s watch error:%s", p.Name(), err.Error()))
+	}
+	return nil
+}
+
+func (c *Config) removeRemote(r *remote) {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	for i, item := range c.remotes {
+		if item == r {
+			c.remotes = append(c.remotes[:i], c.remotes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Config) getRemotes() []*remote {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	return append([]*remote{}, c.remotes...)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testProvider struct {
+	values   map[string]interface{}
+	err      error
+	onChange func()
+	mutex    sync.Mutex
+}
+
+func (p *testProvider) Name() string {
+	return "test"
+}
+
+func (p *testProvider) Load() (map[string]interface{}, error) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	return p.values, p.err
+}
+
+func (p *testProvider) Watch(onChange func()) error {
+	p.onChange = onChange
+	return nil
+}
+
+func (p *testProvider) Close() error {
+	return nil
+}
+
+func (p *testProvider) set(values map[string]interface{}, err error) {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	p.values, p.err = values, err
+}
+
+func TestConfig_AddRemote(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.toml")
+	cache := filepath.Join(dir, "cache", "remote.json")
+	writeFile(t, filename, "[app]\nname = \"local\"\nversion = \"1\"\n")
+	c, err := NewConfigWithSetting(Setting{Filename: filename, Debounce: 10 * time.Millisecond})
+	assert.Nil(t, err)
+
+	p := &testProvider{values: map[string]interface{}{"app": map[string]interface{}{"name": "remote"}}}
+	assert.Nil(t, c.AddRemote(p, cache))
+	assert.Equal(t, "remote", c.GetHandler().GetString("app.name"))
+	assert.Equal(t, "1", c.GetHandler().GetString("app.version"))
+	assert.Equal(t, SourceRemote+"test", c.Source("app.name"))
+
+	//远程变化 推送到订阅者
+	changed := make(chan interface{}, 1)
+	c.OnChange("app.name", func(old, new map[string]interface{}) {
+		changed <- new["app.name"]
+	})
+	p.set(map[string]interface{}{"app": map[string]interface{}{"name": "remote2"}}, nil)
+	p.onChange()
+	select {
+	case name := <-changed:
+		assert.Equal(t, "remote2", name)
+	case <-time.After(time.Second):
+		t.Fatal("remote change not notify")
+	}
+	assert.Nil(t, c.Close())
+
+	//远程不可用 使用缓存启动
+	c2, err := NewConfig(filename)
+	assert.Nil(t, err)
+	assert.Nil(t, c2.AddRemote(&testProvider{err: errors.New("unreachable")}, cache))
+	assert.Equal(t, "remote2", c2.GetHandler().GetString("app.name"))
+	assert.Equal(t, SourceRemote+"test (cache)", c2.Source("app.name"))
+
+	//没有缓存 返回错误
+	c3, err := NewConfig(filename)
+	assert.Nil(t, err)
+	assert.NotNil(t, c3.AddRemote(&testProvider{err: errors.New("unreachable")}, filepath.Join(dir, "none.json")))
+	assert.Equal(t, "local", c3.GetHandler().GetString("app.name"))
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+//密钥引用, 读取配置时自动解析, Dump 时隐藏
+//usage:
+//
+//	[mongo]
+//	hosts = "${MONGO_HOSTS}"                       环境变量, 可出现在字符串任意位置
+//	password = "file:/run/secrets/mongo_password"  文件内容, 去掉末尾换行
+//	username = "enc:Vb3...=="                      AES-GCM 密文, 使用 EncryptValue 或 lego encrypt 生成
+//
+//	密钥为 base64 编码的 16/24/32 字节, 读取顺序:
+//	Setting.SecretKey -> Setting.SecretKeyFile -> 环境变量 LEGO_SECRET_KEY -> 环境变量 LEGO_SECRET_KEY_FILE 指定的文件
+
+const (
+	SecretKeyEnv     = "LEGO_SECRET_KEY"
+	SecretKeyFileEnv = "LEGO_SECRET_KEY_FILE"
+
+	secretFilePrefix = "file:"
+	secretEncPrefix  = "enc:"
+
+	//隐藏后的值
+	Redacted = "******"
+)
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+//名称包含以下关键字的配置项 Dump 时同样隐藏
+var sensitiveWords = []string{"password", "passwd", "secret", "token", "credential"}
+
+//解析所有配置项中的密钥引用, 返回解析后的配置及包含引用的key
+//解析结果写入配置层而不是 Set, 避免按父节点读取时丢失同级的其它配置项
+func resolveSecrets(v *viper.Viper, setting Setting) (*viper.Viper, map[string]bool, error) {
+	r := &resolver{setting: setting}
+	settings := v.AllSettings()
+	secrets := make(map[string]bool)
+	var failed []string
+	for _, key := range v.AllKeys() {
+		value, ok, err := r.resolveValue(v.Get(key))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", key, err.Error()))
+			continue
+		}
+		if ok {
+			setPath(settings, key, value)
+			secrets[key] = true
+		}
+	}
+	if len(failed) > 0 {
+		return nil, nil, errors.New(fmt.Sprintf("resolve config secret error! %s", strings.Join(failed, "; ")))
+	}
+	if len(secrets) == 0 {
+		return v, secrets, nil
+	}
+	resolved, err := rebuild(v, settings, setting.Format)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("merge config secret error:%s", err.Error()))
+	}
+	return resolved, secrets, nil
+}
+
+type resolver struct {
+	setting Setting
+	key     []byte
+}
+
+//解析单个值, 支持字符串与字符串列表
+func (r *resolver) resolveValue(value interface{}) (interface{}, bool, error) {
+	switch val := value.(type) {
+	case string:
+		return r.resolveString(val)
+	case []string:
+		list := make([]string, 0, len(val))
+		found := false
+		for _, item := range val {
+			s, ok, err := r.resolveString(item)
+			if err != nil {
+				return nil, false, err
+			}
+			found = found || ok
+			list = append(list, s)
+		}
+		return list, found, nil
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+		found := false
+		for _, item := range val {
+			s, ok, err := r.resolveValue(item)
+			if err != nil {
+				return nil, false, err
+			}
+			found = found || ok
+			list = append(list, s)
+		}
+		return list, found, nil
+	}
+	return value, false, nil
+}
+
+func (r *resolver) resolveString(value string) (string, bool, error) {
+	switch {
+	case strings.HasPrefix(value, secretFilePrefix):
+		filename := strings.TrimPrefix(value, secretFilePrefix)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", false, errors.New(fmt.Sprintf("read secret file:%s error:%s", filename, err.Error()))
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	case strings.HasPrefix(value, secretEncPrefix):
+		if r.key == nil {
+			key, err := loadSecretKey(r.setting)
+			if err != nil {
+				return "", false, err
+			}
+			r.key = key
+		}
+		plain, err := DecryptValue(r.key, value)
+		if err != nil {
+			return "", false, err
+		}
+		return plain, true, nil
+	}
+
+	if !envPattern.MatchString(value) {
+		return value, false, nil
+	}
+	var missing []string
+	resolved := envPattern.ReplaceAllStringFunc(value, func(s string) string {
+		name := envPattern.FindStringSubmatch(s)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return env
+	})
+	if len(missing) > 0 {
+		return "", false, errors.New(fmt.Sprintf("environment variable not set:%s", strings.Join(missing, ",")))
+	}
+	return resolved, true, nil
+}
+
+//读取解密密钥
+func loadSecretKey(setting Setting) ([]byte, error) {
+	encoded := setting.SecretKey
+	if len(encoded) == 0 && len(setting.SecretKeyFile) > 0 {
+		data, err := ioutil.ReadFile(setting.SecretKeyFile)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("read secret key file:%s error:%s", setting.SecretKeyFile, err.Error()))
+		}
+		encoded = string(data)
+	}
+	if len(encoded) == 0 {
+		encoded = os.Getenv(SecretKeyEnv)
+	}
+	if len(encoded) == 0 {
+		if filename := os.Getenv(SecretKeyFileEnv); len(filename) > 0 {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("read secret key file:%s error:%s", filename, err.Error()))
+			}
+			encoded = string(data)
+		}
+	}
+	if len(encoded) == 0 {
+		return nil, errors.New(fmt.Sprintf("secret key not set, need %s or %s", SecretKeyEnv, SecretKeyFileEnv))
+	}
+	return ParseSecretKey(encoded)
+}
+
+//解析 base64 编码的密钥
+func ParseSecretKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("secret key need base64 error:%s", err.Error()))
+	}
+	if l := len(key); l != 16 && l != 24 && l != 32 {
+		return nil, errors.New(fmt.Sprintf("secret key need 16, 24 or 32 bytes, got:%d", l))
+	}
+	return key, nil
+}
+
+//生成 base64 编码的 32 字节随机密钥
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+//加密配置值, 返回 enc:<base64(nonce+密文)>
+func EncryptValue(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return secretEncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+//解密 enc: 配置值
+func DecryptValue(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretEncPrefix))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("decrypt secret need base64 error:%s", err.Error()))
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("decrypt secret error: data too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("decrypt secret error:%s", err.Error()))
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("secret key error:%s", err.Error()))
+	}
+	return cipher.NewGCM(block)
+}
+
+//是否为需要隐藏的配置项: 包含密钥引用 或 名称包含敏感关键字
+func (c *Config) IsSecret(key string) bool {
+	key = strings.ToLower(key)
+	c.mutex.RLock()
+	secret := c.secrets[key]
+	c.mutex.RUnlock()
+	if secret {
+		return true
+	}
+	name := key[strings.LastIndex(key, ".")+1:]
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+//需要隐藏的配置项返回 Redacted, 用于输出日志
+func (c *Config) Redact(key string, value interface{}) interface{} {
+	if c.IsSecret(key) {
+		return Redacted
+	}
+	return value
+}
+
+//隐藏变更中的敏感配置项, 用于输出 OnChange 的 old new
+func (c *Config) RedactValues(values map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		redacted[key] = c.Redact(key, value)
+	}
+	return redacted
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptValue(t *testing.T) {
+	encoded, err := GenerateSecretKey()
+	assert.Nil(t, err)
+	key, err := ParseSecretKey(encoded)
+	assert.Nil(t, err)
+
+	value, err := EncryptValue(key, "p@ss")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(value, "enc:"))
+	plain, err := DecryptValue(key, value)
+	assert.Nil(t, err)
+	assert.Equal(t, "p@ss", plain)
+
+	other, _ := GenerateSecretKey()
+	otherKey, _ := ParseSecretKey(other)
+	_, err = DecryptValue(otherKey, value)
+	assert.NotNil(t, err, "wrong key")
+
+	_, err = ParseSecretKey("c2hvcnQ=")
+	assert.NotNil(t, err, "short key")
+}
+
+func TestConfig_Secret(t *testing.T) {
+	dir := t.TempDir()
+	encoded, _ := GenerateSecretKey()
+	key, _ := ParseSecretKey(encoded)
+	enc, _ := EncryptValue(key, "kafka-secret")
+	secretFile := filepath.Join(dir, "mongo_password")
+	writeFile(t, secretFile, "mongo-secret\n")
+	keyFile := filepath.Join(dir, "secret.key")
+	writeFile(t, keyFile, encoded+"\n")
+
+	assert.Nil(t, os.Setenv("LEGO_TEST_MONGO_HOST", "10.0.0.1"))
+	defer os.Unsetenv("LEGO_TEST_MONGO_HOST")
+
+	filename := filepath.Join(dir, "config.toml")
+	writeFile(t, filename, `
+[mongo]
+hosts = "${LEGO_TEST_MONGO_HOST}:27017"
+password = "file:`+secretFile+`"
+max_pool_size = 5
+[kafka]
+brokers = ["${LEGO_TEST_MONGO_HOST}:9092"]
+sasl = "`+enc+`"
+[app]
+name = "plain"
+`)
+	c, err := NewConfigWithSetting(Setting{Filename: filename, SecretKeyFile: keyFile})
+	assert.Nil(t, err)
+	v := c.GetHandler()
+	assert.Equal(t, "10.0.0.1:27017", v.GetString("mongo.hosts"))
+	assert.Equal(t, "mongo-secret", v.GetString("mongo.password"))
+	assert.Equal(t, "kafka-secret", v.GetString("kafka.sasl"))
+	assert.Equal(t, []string{"10.0.0.1:9092"}, v.GetStringSlice("kafka.brokers"))
+
+	//按父节点读取时保留同级的普通配置项
+	var mongo struct {
+		Hosts       string `mapstructure:"hosts"`
+		Password    string `mapstructure:"password"`
+		MaxPoolSize int    `mapstructure:"max_pool_size"`
+	}
+	assert.Nil(t, c.Bind("mongo", &mongo))
+	assert.Equal(t, "10.0.0.1:27017", mongo.Hosts)
+	assert.Equal(t, "mongo-secret", mongo.Password)
+	assert.Equal(t, 5, mongo.MaxPoolSize)
+	assert.Equal(t, map[string]interface{}{
+		"hosts":         "10.0.0.1:27017",
+		"password":      "mongo-secret",
+		"max_pool_size": int64(5),
+	}, v.GetStringMap("mongo"))
+
+	//Dump 隐藏
+	dump := c.Dump()
+	for _, secret := range []string{"10.0.0.1", "mongo-secret", "kafka-secret"} {
+		assert.False(t, strings.Contains(dump, secret), "dump contains:"+secret)
+	}
+	assert.True(t, strings.Contains(dump, "app.name = plain"))
+	assert.True(t, c.IsSecret("mongo.password"))
+	assert.False(t, c.IsSecret("app.name"))
+
+	//未设置密钥
+	_, err = NewConfig(filename)
+	assert.NotNil(t, err)
+
+	//环境变量不存在
+	writeFile(t, filename, "[mongo]\nhosts = \"${LEGO_TEST_NOT_SET}\"\n")
+	_, err = NewConfig(filename)
+	assert.True(t, strings.Contains(err.Error(), "LEGO_TEST_NOT_SET"))
+}
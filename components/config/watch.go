@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+//默认防抖时间, 编辑器保存时会产生多次写入/重命名事件
+const DefaultDebounce = 500 * time.Millisecond
+
+//配置变更回调, old new 只包含订阅前缀下发生变化的配置项, 新增的key不在old中, 删除的key不在new中
+type ChangeFunc func(old, new map[string]interface{})
+
+//重新加载校验, 返回错误时拒绝新配置
+type Validator func(c *Config) error
+
+type subscriber struct {
+	prefix string
+	f      ChangeFunc
+}
+
+//订阅前缀下配置项的变更, keyPrefix 为空时订阅全部
+//	cf.OnChange("mongo.instance.db1", f) 匹配 mongo.instance.db1.hosts, 不匹配 mongo.instance.db10.hosts
+func (c *Config) OnChange(keyPrefix string, f ChangeFunc) {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	c.subscribers = append(c.subscribers, &subscriber{prefix: strings.ToLower(keyPrefix), f: f})
+}
+
+//添加重新加载校验
+func (c *Config) AddValidator(f Validator) {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	c.validators = append(c.validators, f)
+}
+
+//设置监听时重新加载失败的处理, 默认输出到标准日志
+func (c *Config) OnError(f func(err error)) {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	c.errorHandler = f
+}
+
+func (c *Config) getValidators() []Validator {
+	defer c.hookMutex.Unlock()
+	c.hookMutex.Lock()
+	return append([]Validator{}, c.validators...)
+}
+
+func (c *Config) reportError(err error) {
+	c.hookMutex.Lock()
+	f := c.errorHandler
+	c.hookMutex.Unlock()
+	if f == nil {
+		log.Printf("config reload error:%s", err.Error())
+		return
+	}
+	f(err)
+}
+
+//通知订阅者
+func (c *Config) notify(old, new *viper.Viper) {
+	oldValues, newValues := diff(old, new)
+	if len(oldValues) == 0 && len(newValues) == 0 {
+		return
+	}
+	c.hookMutex.Lock()
+	subscribers := append([]*subscriber{}, c.subscribers...)
+	c.hookMutex.Unlock()
+
+	for _, s := range subscribers {
+		o, n := filterPrefix(oldValues, s.prefix), filterPrefix(newValues, s.prefix)
+		if len(o) == 0 && len(n) == 0 {
+			continue
+		}
+		c.callSubscriber(s, o, n)
+	}
+}
+
+//回调panic不影响其他订阅者
+func (c *Config) callSubscriber(s *subscriber, old, new map[string]interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.reportError(errors.New(fmt.Sprintf("config subscriber prefix:%s panic:%v", s.prefix, r)))
+		}
+	}()
+	s.f(old, new)
+}
+
+//计算变更的配置项
+func diff(old, new *viper.Viper) (map[string]interface{}, map[string]interface{}) {
+	oldValues := make(map[string]interface{})
+	newValues := make(map[string]interface{})
+	for _, key := range old.AllKeys() {
+		ov := old.Get(key)
+		if !new.IsSet(key) {
+			oldValues[key] = ov
+			continue
+		}
+		if nv := new.Get(key); !reflect.DeepEqual(ov, nv) {
+			oldValues[key] = ov
+			newValues[key] = nv
+		}
+	}
+	for _, key := range new.AllKeys() {
+		if !old.IsSet(key) {
+			newValues[key] = new.Get(key)
+		}
+	}
+	return oldValues, newValues
+}
+
+func filterPrefix(values map[string]interface{}, prefix string) map[string]interface{} {
+	if len(prefix) == 0 {
+		return values
+	}
+	filtered := make(map[string]interface{})
+	for key, value := range values {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+//监听配置文件及环境覆盖文件变化并重新加载
+//监听所在目录, 支持编辑器 写入临时文件再重命名 的保存方式
+func (c *Config) WatchReConfig() error {
+	if c.Setting.Type != TypeFile {
+		return errors.New("only file config can watch")
+	}
+	defer c.watchMutex.Unlock()
+	c.watchMutex.Lock()
+	if c.watcher != nil {
+		return nil
+	}
+
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, filename := range []string{c.Setting.Filename, c.OverlayFilename()} {
+		if len(filename) == 0 {
+			continue
+		}
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		files[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.New(fmt.Sprintf("config watch error:%s", err.Error()))
+	}
+	for dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := w.Add(dir); err != nil {
+			_ = w.Close()
+			return errors.New(fmt.Sprintf("config watch dir:%s error:%s", dir, err.Error()))
+		}
+	}
+	c.watcher = w
+	go c.watch(w, files)
+	return nil
+}
+
+func (c *Config) watch(w *fsnotify.Watcher, files map[string]bool) {
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if !files[filepath.Clean(e.Name)] || e.Op == fsnotify.Chmod {
+				continue
+			}
+			c.scheduleReload()
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			c.reportError(err)
+		}
+	}
+}
+
+//防抖重新加载, 等待时间内的多次变化只加载一次
+func (c *Config) scheduleReload() {
+	debounce := c.Setting.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	defer c.timerMutex.Unlock()
+	c.timerMutex.Lock()
+	if c.reloadTimer == nil {
+		c.reloadTimer = time.AfterFunc(debounce, func() {
+			if err := c.Reload(); err != nil {
+				c.reportError(err)
+			}
+		})
+		return
+	}
+	c.reloadTimer.Reset(debounce)
+}
+
+//停止监听文件及远程配置
+func (c *Config) Close() error {
+	c.timerMutex.Lock()
+	if c.reloadTimer != nil {
+		c.reloadTimer.Stop()
+	}
+	c.timerMutex.Unlock()
+
+	var err error
+	for _, r := range c.getRemotes() {
+		if e := r.provider.Close(); e != nil {
+			err = e
+		}
+	}
+
+	defer c.watchMutex.Unlock()
+	c.watchMutex.Lock()
+	if c.watcher == nil {
+		return err
+	}
+	if e := c.watcher.Close(); e != nil {
+		err = e
+	}
+	c.watcher = nil
+	return err
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_OnChange(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, filename, "[log]\nlevel = \"info\"\npath = \"/tmp\"\n[app]\nname = \"v1\"\n")
+	c, err := NewConfig(filename)
+	assert.Nil(t, err)
+
+	var logOld, logNew map[string]interface{}
+	all := 0
+	c.OnChange("log", func(old, new map[string]interface{}) {
+		logOld, logNew = old, new
+	})
+	c.OnChange("", func(old, new map[string]interface{}) {
+		all++
+	})
+	c.OnChange("mongo", func(old, new map[string]interface{}) {
+		t.Error("mongo not changed")
+	})
+
+	writeFile(t, filename, "[log]\nlevel = \"debug\"\nformat = \"json\"\n[app]\nname = \"v2\"\n")
+	assert.Nil(t, c.Reload())
+	assert.Equal(t, map[string]interface{}{"log.level": "info", "log.path": "/tmp"}, logOld)
+	assert.Equal(t, map[string]interface{}{"log.level": "debug", "log.format": "json"}, logNew)
+	assert.Equal(t, 1, all)
+
+	//未变化 不通知
+	assert.Nil(t, c.Reload())
+	assert.Equal(t, 1, all)
+}
+
+func TestConfig_ReloadRejected(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, filename, "[app]\nname = \"v1\"\n")
+	c, err := NewConfig(filename)
+	assert.Nil(t, err)
+	c.AddValidator(func(c *Config) error {
+		if c.GetHandler().GetString("app.name") == "invalid" {
+			return errors.New("invalid name")
+		}
+		return nil
+	})
+	c.OnChange("", func(old, new map[string]interface{}) {
+		t.Error("rejected reload need not notify")
+	})
+
+	//解析失败
+	writeFile(t, filename, "[app\nname = ")
+	assert.NotNil(t, c.Reload())
+	//校验失败
+	writeFile(t, filename, "[app]\nname = \"invalid\"\n")
+	assert.NotNil(t, c.Reload())
+	assert.Equal(t, "v1", c.GetHandler().GetString("app.name"))
+}
+
+func TestConfig_WatchReConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, filename, "[app]\nname = \"v1\"\n")
+	c, err := NewConfigWithSetting(Setting{Filename: filename, Debounce: 50 * time.Millisecond})
+	assert.Nil(t, err)
+
+	var mutex sync.Mutex
+	var names []interface{}
+	c.OnChange("app.name", func(old, new map[string]interface{}) {
+		defer mutex.Unlock()
+		mutex.Lock()
+		names = append(names, new["app.name"])
+	})
+	assert.Nil(t, c.WatchReConfig())
+	defer c.Close()
+
+	//连续写入 只加载一次
+	for _, name := range []string{"v2", "v3", "v4"} {
+		writeFile(t, filename, "[app]\nname = \""+name+"\"\n")
+	}
+	//重命名覆盖
+	tmp := filename + ".tmp"
+	writeFile(t, tmp, "[app]\nname = \"v5\"\n")
+	assert.Nil(t, os.Rename(tmp, filename))
+
+	assert.Eventually(t, func() bool {
+		return c.GetHandler().GetString("app.name") == "v5"
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	mutex.Lock()
+	assert.Equal(t, []interface{}{"v5"}, names)
+	mutex.Unlock()
+}
@@ -1,9 +1,15 @@
 package crontab
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jeevi-cao/lego/components/log"
 )
 
 //@see https://github.com/go-co-op/gocron
@@ -20,11 +26,25 @@ import (
 //
 //	crontab.Clear()
 //	crontab.Stop()
+//
+//	//任务函数获取携带 job 及 trace id 的 context, 用于日志及下游请求
+//	_, _ = scheduler.Every(1).Minute().Do(JobFunc("sync", func(ctx context.Context) {
+//		log.WithContext(ctx, logger).Info("sync")
+//	}))
+//
+//	//Shutdown 只等待 Wrap 或 Crontab.JobFunc 包装的任务执行完成, 停止后包装的任务不再执行
+//	_, _ = scheduler.Every(1).Minute().Do(crontab.JobFunc("sync", func(ctx context.Context) {
+//	}))
 
 type Scheduler = *gocron.Scheduler
 
 type Crontab struct {
 	Scheduler *gocron.Scheduler
+	running   int32
+	//执行中的包装任务
+	jobs     sync.WaitGroup
+	stopped  bool
+	jobMutex sync.Mutex
 }
 
 func New() *Crontab {
@@ -42,14 +62,21 @@ func (c *Crontab) AddTaskFunc(callbacks ...func(scheduler Scheduler)) {
 
 //异步启动 不阻塞当前进程
 func (c *Crontab) StartAsync() {
+	atomic.StoreInt32(&c.running, 1)
 	c.Scheduler.StartAsync()
 }
 
 //阻塞开始
 func (c *Crontab) StartBlocking() {
+	atomic.StoreInt32(&c.running, 1)
 	c.Scheduler.StartBlocking()
 }
 
+//是否在运行
+func (c *Crontab) IsRunning() bool {
+	return atomic.LoadInt32(&c.running) == 1
+}
+
 //根据tag删除job
 func (c *Crontab) RemoveJobByTag(tag string) error {
 	return c.Scheduler.RemoveJobByTag(tag)
@@ -67,5 +94,59 @@ func (c *Crontab) Len() int {
 
 //停止任务
 func (c *Crontab) Stop() {
-	c.Scheduler.Stop()
+	if atomic.CompareAndSwapInt32(&c.running, 1, 0) {
+		c.Scheduler.Stop()
+	}
+}
+
+//停止调度并等待执行中的包装任务完成, ctx 超时返回错误
+//停止前已调度但尚未开始执行的包装任务不再执行
+func (c *Crontab) Shutdown(ctx context.Context) error {
+	c.Stop()
+	c.jobMutex.Lock()
+	c.stopped = true
+	c.jobMutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		c.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//包装任务函数, 记录执行中的任务供 Shutdown 等待
+func (c *Crontab) Wrap(f func()) func() {
+	return func() {
+		c.jobMutex.Lock()
+		if c.stopped {
+			c.jobMutex.Unlock()
+			return
+		}
+		c.jobs.Add(1)
+		c.jobMutex.Unlock()
+		defer c.jobs.Done()
+		f()
+	}
+}
+
+//同 JobFunc, 并记录执行中的任务供 Shutdown 等待
+func (c *Crontab) JobFunc(name string, f func(ctx context.Context)) func() {
+	return c.Wrap(JobFunc(name, f))
+}
+
+//包装任务函数, 每次执行生成新的 trace id, 与任务名称一起写入 ctx 的日志字段
+func JobFunc(name string, f func(ctx context.Context)) func() {
+	return func() {
+		ctx := log.NewContext(context.Background(), logrus.Fields{
+			log.FieldJob:     name,
+			log.FieldTraceId: log.NewTraceId(),
+			log.FieldSpanId:  log.NewSpanId(),
+		})
+		f(ctx)
+	}
 }
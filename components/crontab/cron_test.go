@@ -1,11 +1,15 @@
 package crontab
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/log"
 )
 
 //添加任务方法
@@ -64,3 +68,67 @@ func TestCrontab_RemoveJobByTag(t *testing.T) {
 	err := crontab.RemoveJobByTag(tags[0])
 	assert.Equal(t, err, nil, "remove tag success")
 }
+
+//运行状态
+func TestCrontab_IsRunning(t *testing.T) {
+	crontab := New()
+	assert.False(t, crontab.IsRunning(), "not start")
+
+	crontab.StartAsync()
+	assert.True(t, crontab.IsRunning(), "start async")
+
+	crontab.Stop()
+	assert.False(t, crontab.IsRunning(), "stopped")
+}
+
+//等待执行中的任务
+func TestCrontab_Shutdown(t *testing.T) {
+	crontab := New()
+	started := make(chan struct{}, 1)
+	var done int32
+	crontab.AddTaskFunc(func(scheduler Scheduler) {
+		_, _ = scheduler.Every(1).Second().Do(crontab.Wrap(func() {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			time.Sleep(500 * time.Millisecond)
+			atomic.StoreInt32(&done, 1)
+		}))
+	})
+	crontab.StartAsync()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, crontab.Shutdown(ctx))
+	cancel()
+
+	assert.Nil(t, crontab.Shutdown(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&done))
+	assert.False(t, crontab.IsRunning())
+}
+
+//停止前已调度 停止后才开始执行的任务不再执行
+func TestCrontab_ShutdownDispatched(t *testing.T) {
+	crontab := New()
+	var runs int32
+	f := crontab.Wrap(func() {
+		atomic.AddInt32(&runs, 1)
+	})
+	f()
+	assert.Nil(t, crontab.Shutdown(context.Background()))
+	f()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestJobFunc(t *testing.T) {
+	traces := make([]string, 0)
+	f := JobFunc("sync", func(ctx context.Context) {
+		assert.Equal(t, "sync", log.FieldString(ctx, log.FieldJob))
+		traces = append(traces, log.FieldString(ctx, log.FieldTraceId))
+	})
+	f()
+	f()
+	assert.Equal(t, 2, len(traces))
+	assert.NotEqual(t, traces[0], traces[1], "each run need new trace id")
+}
@@ -0,0 +1,177 @@
+package graceful
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//平滑重启, 监听句柄交给新进程
+//usage:
+//
+//	//父进程 收到 SIGUSR1
+//	pid, err := graceful.Fork(map[string]*os.File{"httpserver": f}, graceful.DefaultTimeout)
+//	if err == nil {
+//		//新进程已就绪, 关闭监听 处理完请求后退出
+//	}
+//
+//	//新进程
+//	l, _ := graceful.Listener("httpserver")
+//	...
+//	graceful.Ready()
+//
+//	句柄按名称顺序从fd 3开始传递, 名称列表写入环境变量 LEGO_GRACEFUL_FDS
+//	最后一个句柄为就绪通知管道, 新进程就绪后写入一个字节, 新进程退出或超时视为失败
+
+const (
+	//继承的句柄名称, 逗号分隔
+	FdsEnv = "LEGO_GRACEFUL_FDS"
+	//就绪通知管道名称
+	readyName = "ready"
+	//第一个继承的句柄, 0 1 2 为标准输入输出
+	firstFd = 3
+	//默认等待新进程就绪时间
+	DefaultTimeout = 30 * time.Second
+)
+
+var inherited map[string]*os.File
+var mutex sync.Mutex
+var once sync.Once
+
+//解析继承的句柄
+func load() {
+	once.Do(func() {
+		inherited = make(map[string]*os.File)
+		env := os.Getenv(FdsEnv)
+		if len(env) == 0 {
+			return
+		}
+		for i, name := range strings.Split(env, ",") {
+			fd := uintptr(firstFd + i)
+			inherited[name] = os.NewFile(fd, name)
+		}
+	})
+}
+
+//是否由平滑重启启动
+func IsChild() bool {
+	load()
+	return len(inherited) > 0
+}
+
+//取出继承的句柄, 每个名称只能取一次, 不存在返回nil
+func File(name string) *os.File {
+	load()
+	defer mutex.Unlock()
+	mutex.Lock()
+	f := inherited[name]
+	delete(inherited, name)
+	return f
+}
+
+//继承的监听, 不存在返回nil
+func Listener(name string) (net.Listener, error) {
+	f := File(name)
+	if f == nil {
+		return nil, nil
+	}
+	//FileListener 复制句柄
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("inherit listener:%s error:%s", name, err.Error()))
+	}
+	return l, nil
+}
+
+//通知父进程已就绪, 非平滑重启启动时忽略
+func Ready() error {
+	f := File(readyName)
+	if f == nil {
+		return nil
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte{1}); err != nil {
+		return errors.New(fmt.Sprintf("notify ready error:%s", err.Error()))
+	}
+	return nil
+}
+
+//以相同参数启动新进程并传递句柄, 等待新进程就绪后返回pid
+//新进程退出或超时未就绪时返回错误, 超时会结束新进程
+func Fork(files map[string]*os.File, timeout time.Duration) (int, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	extra := make([]*os.File, 0, len(names)+1)
+	for _, name := range names {
+		extra = append(extra, files[name])
+	}
+	names = append(names, readyName)
+	extra = append(extra, w)
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extra
+	cmd.Env = append(environ(), FdsEnv+"="+strings.Join(names, ","))
+	err = cmd.Start()
+	//子进程持有写端, 父进程关闭后子进程退出时读到EOF
+	_ = w.Close()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("start new process error:%s", err.Error()))
+	}
+	pid := cmd.Process.Pid
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if n, _ := r.Read(buf); n == 1 {
+			ready <- nil
+			return
+		}
+		ready <- errors.New(fmt.Sprintf("new process pid:%d exit before ready", pid))
+	}()
+	//回收子进程, 父进程先退出时由init回收
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	select {
+	case err := <-ready:
+		return pid, err
+	case <-time.After(timeout):
+		_ = cmd.Process.Kill()
+		return pid, errors.New(fmt.Sprintf("new process pid:%d not ready after %s", pid, timeout))
+	}
+}
+
+//当前环境变量, 去掉继承的句柄名称
+func environ() []string {
+	env := make([]string, 0)
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, FdsEnv+"=") {
+			env = append(env, e)
+		}
+	}
+	return env
+}
@@ -0,0 +1,80 @@
+package graceful
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//新进程行为
+const modeEnv = "GRACEFUL_TEST_MODE"
+
+//测试二进制作为新进程启动时不执行测试
+func TestMain(m *testing.M) {
+	if IsChild() {
+		child()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func child() {
+	if os.Getenv(modeEnv) == "exit" {
+		os.Exit(1)
+	}
+	l, err := Listener("http")
+	if err != nil || l == nil {
+		os.Exit(2)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("child"))
+	})}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	if err := Ready(); err != nil {
+		os.Exit(3)
+	}
+	time.Sleep(time.Second)
+	os.Exit(0)
+}
+
+func TestFork(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	f, err := l.(*net.TCPListener).File()
+	assert.Nil(t, err)
+	defer f.Close()
+
+	pid, err := Fork(map[string]*os.File{"http": f}, 10*time.Second)
+	assert.Nil(t, err)
+	assert.True(t, pid > 0)
+
+	//关闭父进程监听, 新进程继续处理
+	_ = l.Close()
+	resp, err := http.Get("http://" + l.Addr().String())
+	assert.Nil(t, err)
+	buf := make([]byte, 5)
+	_, _ = resp.Body.Read(buf)
+	_ = resp.Body.Close()
+	assert.Equal(t, "child", string(buf))
+}
+
+func TestFork_Exit(t *testing.T) {
+	os.Setenv(modeEnv, "exit")
+	defer os.Unsetenv(modeEnv)
+	_, err := Fork(nil, 10*time.Second)
+	assert.NotNil(t, err)
+}
+
+func TestNotChild(t *testing.T) {
+	assert.False(t, IsChild())
+	l, err := Listener("http")
+	assert.Nil(t, err)
+	assert.Nil(t, l)
+	assert.Nil(t, Ready())
+}
@@ -0,0 +1,241 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//健康检查 与 就绪检查
+//usage:
+//
+//	h := New(Setting{Timeout: 3 * time.Second, CacheTTL: time.Second})
+//	h.Register("mongo.db1", func(ctx context.Context) error {
+//		return mg.Ping(ctx)
+//	})
+//	h.Routes(engine)
+//	h.SetReady(true)
+//
+//	GET /healthz  所有检查通过返回200, 否则503
+//	GET /readyz   未就绪或检查失败返回503
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+//检查函数, 需要响应ctx超时
+type CheckFunc func(ctx context.Context) error
+
+type Health struct {
+	Setting *Setting
+
+	checks  map[string]CheckFunc
+	ready   int32
+	mutex   sync.RWMutex
+	cached  *Report
+	cacheAt time.Time
+	cacheMu sync.Mutex
+}
+
+type Setting struct {
+	//单个检查超时时间 默认3秒
+	Timeout time.Duration
+	//检查结果缓存时间 默认1秒, 避免探针频繁访问下游
+	CacheTTL time.Duration
+	//默认 /healthz
+	HealthzPath string
+	//默认 /readyz
+	ReadyzPath string
+}
+
+//检查报告
+type Report struct {
+	Status string             `json:"status"`
+	Ready  bool               `json:"ready"`
+	Time   time.Time          `json:"time"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+//单项检查结果
+type Result struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+func New(setting Setting) *Health {
+	if setting.Timeout <= 0 {
+		setting.Timeout = 3 * time.Second
+	}
+	if setting.CacheTTL <= 0 {
+		setting.CacheTTL = time.Second
+	}
+	if len(setting.HealthzPath) == 0 {
+		setting.HealthzPath = "/healthz"
+	}
+	if len(setting.ReadyzPath) == 0 {
+		setting.ReadyzPath = "/readyz"
+	}
+	return &Health{Setting: &setting, checks: make(map[string]CheckFunc)}
+}
+
+//注册检查, 同名覆盖
+func (h *Health) Register(name string, f CheckFunc) {
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	h.checks[name] = f
+}
+
+//删除检查
+func (h *Health) Unregister(name string) {
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	delete(h.checks, name)
+}
+
+//检查项名称
+func (h *Health) Names() []string {
+	defer h.mutex.RUnlock()
+	h.mutex.RLock()
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//设置就绪状态
+func (h *Health) SetReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&h.ready, 1)
+	} else {
+		atomic.StoreInt32(&h.ready, 0)
+	}
+}
+
+func (h *Health) IsReady() bool {
+	return atomic.LoadInt32(&h.ready) == 1
+}
+
+//执行所有检查, 缓存时间内直接返回上次结果
+func (h *Health) Check(ctx context.Context) *Report {
+	defer h.cacheMu.Unlock()
+	h.cacheMu.Lock()
+
+	if h.cached != nil && time.Since(h.cacheAt) < h.Setting.CacheTTL {
+		return h.withReady(h.cached)
+	}
+	report := h.run(ctx)
+	h.cached = report
+	h.cacheAt = time.Now()
+	return h.withReady(report)
+}
+
+//并行执行所有检查
+func (h *Health) run(ctx context.Context) *Report {
+	h.mutex.RLock()
+	checks := make(map[string]CheckFunc, len(h.checks))
+	for name, f := range h.checks {
+		checks[name] = f
+	}
+	h.mutex.RUnlock()
+
+	report := &Report{Status: StatusUp, Time: time.Now(), Checks: make(map[string]*Result, len(checks))}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for name, f := range checks {
+		go func(name string, f CheckFunc) {
+			defer wg.Done()
+			result := h.runCheck(ctx, f)
+			mutex.Lock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+			mutex.Unlock()
+		}(name, f)
+	}
+	wg.Wait()
+	return report
+}
+
+//单项检查 超时或panic视为失败
+func (h *Health) runCheck(ctx context.Context, f CheckFunc) *Result {
+	ctx, cancel := context.WithTimeout(ctx, h.Setting.Timeout)
+	defer cancel()
+
+	t1 := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New(fmt.Sprintf("panic: %v", r))
+			}
+		}()
+		done <- f(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New(fmt.Sprintf("check timeout after %s", h.Setting.Timeout))
+	}
+	result := &Result{Status: StatusUp, Latency: time.Since(t1).String()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+//复制报告并填充当前就绪状态
+func (h *Health) withReady(report *Report) *Report {
+	r := *report
+	r.Ready = h.IsReady()
+	return &r
+}
+
+//健康检查 handler
+func (h *Health) HealthzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := h.Check(c.Request.Context())
+		code := http.StatusOK
+		if report.Status != StatusUp {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
+
+//就绪检查 handler, 未就绪时不执行检查
+func (h *Health) ReadyzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.IsReady() {
+			c.JSON(http.StatusServiceUnavailable, &Report{Status: StatusDown, Ready: false, Time: time.Now()})
+			return
+		}
+		report := h.Check(c.Request.Context())
+		code := http.StatusOK
+		if report.Status != StatusUp || !report.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
+
+//注册路由
+func (h *Health) Routes(engine *gin.Engine) {
+	engine.GET(h.Setting.HealthzPath, h.HealthzHandler())
+	engine.GET(h.Setting.ReadyzPath, h.ReadyzHandler())
+}
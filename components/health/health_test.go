@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(engine *gin.Engine, path string) (int, *Report) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	engine.ServeHTTP(w, req)
+	report := &Report{}
+	_ = json.Unmarshal(w.Body.Bytes(), report)
+	return w.Code, report
+}
+
+func TestHealth_Check(t *testing.T) {
+	h := New(Setting{Timeout: 50 * time.Millisecond, CacheTTL: time.Millisecond})
+	h.Register("ok", func(ctx context.Context) error { return nil })
+	h.Register("fail", func(ctx context.Context) error { return errors.New("down") })
+	h.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	report := h.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["ok"].Status)
+	assert.Equal(t, "down", report.Checks["fail"].Error)
+	assert.Equal(t, StatusDown, report.Checks["slow"].Status, "timeout need down")
+
+	h.Unregister("fail")
+	h.Unregister("slow")
+	time.Sleep(2 * time.Millisecond)
+	assert.Equal(t, StatusUp, h.Check(context.Background()).Status)
+}
+
+func TestHealth_Cache(t *testing.T) {
+	var count int32
+	h := New(Setting{CacheTTL: time.Minute})
+	h.Register("count", func(ctx context.Context) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	})
+	h.Check(context.Background())
+	h.Check(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count), "need cached")
+}
+
+func TestHealth_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	h := New(Setting{})
+	h.Register("ok", func(ctx context.Context) error { return nil })
+	h.Routes(engine)
+
+	code, report := request(engine, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Checks["ok"].Status)
+
+	code, _ = request(engine, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "not ready")
+
+	h.SetReady(true)
+	code, report = request(engine, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.Ready)
+
+	h.SetReady(false)
+	code, _ = request(engine, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "shutdown begin")
+}
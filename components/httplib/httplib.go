@@ -34,9 +34,11 @@ package httplib
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -53,6 +55,8 @@ import (
 	"time"
 
 	"gopkg.in/yaml.v2"
+
+	legolog "github.com/jeevi-cao/lego/components/log"
 )
 
 var defaultSetting = HLSettings{
@@ -232,6 +236,27 @@ func (b *HLRequest) Header(key, value string) *HLRequest {
 	return b
 }
 
+// WithContext set the request context and propagate request id and trace id
+// carried by ctx (see legolog.NewContext) as request headers. The request id
+// header name follows the one stored by the request id middleware
+// (see legolog.RequestIdHeader), default X-Request-Id.
+func (b *HLRequest) WithContext(ctx context.Context) *HLRequest {
+	if ctx == nil {
+		return b
+	}
+	b.req = b.req.WithContext(ctx)
+	if requestId := legolog.FieldString(ctx, legolog.FieldRequestId); len(requestId) > 0 {
+		b.req.Header.Set(legolog.RequestIdHeader(ctx), requestId)
+	}
+	if traceId := legolog.FieldString(ctx, legolog.FieldTraceId); len(traceId) > 0 {
+		b.req.Header.Set("X-Trace-Id", traceId)
+		if len(traceId) == 32 {
+			b.req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceId, legolog.NewSpanId()))
+		}
+	}
+	return b
+}
+
 // SetHost set the request host
 func (b *HLRequest) SetHost(host string) *HLRequest {
 	b.req.Host = host
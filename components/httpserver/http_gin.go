@@ -2,9 +2,15 @@ package httpserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +20,17 @@ type HttpServer struct {
 	Engine *gin.Engine
 	Setting *Setting
 	Server *http.Server
+	//监听, 为空时启动时按 Setting 监听
+	Listener net.Listener
+	//服务退出的错误
+	errs chan error
+	//panic 恢复日志输出, 每个服务独立设置, 不修改 gin 全局输出
+	output atomic.Value
+
+	//可运行时开关的中间件
+	switches    map[string]*int32
+	switchNames []string
+	switchMutex sync.RWMutex
 }
 
 type Setting struct {
@@ -24,27 +41,117 @@ type Setting struct {
 	IsHttps bool
 }
 
+type outputWriter struct {
+	io.Writer
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
 func NewHttpServer(host string, port int, isHttps bool) *HttpServer {
 	e := gin.New()
-	//auto recover
-	e.Use(gin.Recovery())
-
 	c := Setting{Host: host, Port: port, IsHttps: isHttps}
-	return &HttpServer{Engine: e, Setting: &c}
+	h := &HttpServer{Engine: e, Setting: &c}
+	h.output.Store(outputWriter{os.Stderr})
+	//auto recover
+	e.Use(gin.RecoveryWithWriter(writerFunc(func(p []byte) (int, error) {
+		return h.output.Load().(outputWriter).Write(p)
+	})))
+	return h
 }
 
-
+//gin 运行模式为进程全局设置, 会影响同一进程的所有服务
 func (h *HttpServer) SetServerModeRelease() {
 	gin.SetMode(gin.ReleaseMode)
 }
 
+//设置 panic 恢复日志输出, 默认 os.Stderr
+func (h *HttpServer) SetOutput(w io.Writer) *HttpServer {
+	h.output.Store(outputWriter{w})
+	return h
+}
+
 //add middleware
 func (h *HttpServer) SetMiddleware(middleware ...gin.HandlerFunc) *HttpServer {
 	h.Engine.Use(middleware...)
 	return h
 }
 
-func (h *HttpServer) ServerRun() {
+//add middleware that can be enabled or disabled at runtime, executed in the order added
+func (h *HttpServer) SetSwitchMiddleware(name string, enable bool, middleware gin.HandlerFunc) *HttpServer {
+	flag := new(int32)
+	if enable {
+		*flag = 1
+	}
+	h.switchMutex.Lock()
+	if h.switches == nil {
+		h.switches = make(map[string]*int32)
+	}
+	if _, ok := h.switches[name]; !ok {
+		h.switchNames = append(h.switchNames, name)
+	}
+	h.switches[name] = flag
+	h.switchMutex.Unlock()
+
+	h.Engine.Use(func(c *gin.Context) {
+		if atomic.LoadInt32(flag) == 1 {
+			middleware(c)
+		}
+	})
+	return h
+}
+
+//enable the switch middleware in names, disable the others
+func (h *HttpServer) EnableMiddleware(names ...string) {
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+	defer h.switchMutex.RUnlock()
+	h.switchMutex.RLock()
+	for name, flag := range h.switches {
+		if enabled[name] {
+			atomic.StoreInt32(flag, 1)
+		} else {
+			atomic.StoreInt32(flag, 0)
+		}
+	}
+}
+
+//enabled switch middleware names
+func (h *HttpServer) GetEnableMiddleware() []string {
+	defer h.switchMutex.RUnlock()
+	h.switchMutex.RLock()
+	names := make([]string, 0)
+	for _, name := range h.switchNames {
+		if atomic.LoadInt32(h.switches[name]) == 1 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//使用已有的监听, 平滑重启时继承父进程的监听
+func (h *HttpServer) SetListener(l net.Listener) *HttpServer {
+	h.Listener = l
+	return h
+}
+
+//监听的文件句柄, 复制的句柄需调用方关闭
+func (h *HttpServer) ListenerFile() (*os.File, error) {
+	l, ok := h.Listener.(*net.TCPListener)
+	if !ok {
+		return nil, errors.New("http server not listen on tcp")
+	}
+	return l.File()
+}
+
+//监听并在协程中启动服务, 监听失败时返回错误
+//启动后服务异常退出的错误通过 Err 返回
+func (h *HttpServer) ServerRun() error {
 
 	isHttps := h.Setting.IsHttps
 	addr := fmt.Sprintf("%s:%d", h.Setting.Host, h.Setting.Port)
@@ -52,36 +159,60 @@ func (h *HttpServer) ServerRun() {
 		Addr:    addr,
 		Handler: h.Engine,
 	}
+	if h.Listener == nil {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return errors.New(fmt.Sprintf("http server listen %s err:%s", addr, err))
+		}
+		h.Listener = l
+	}
+	l := h.Listener
+	errs := make(chan error, 1)
 	//Coroutine start server
 	go func() {
+		var err error
 		if isHttps {
-			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("http server run https err:%s", err)
-			}
+			err = srv.ServeTLS(l, "", "")
 		} else {
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("graceful server run http err:%s", err)
-			}
+			err = srv.Serve(l)
 		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("http server run err:%s", err)
+			errs <- errors.New(fmt.Sprintf("http server run err:%s", err))
+		}
+		close(errs)
 	}()
 	h.Server = srv
+	h.errs = errs
+	return nil
+}
+
+//服务异常退出时返回错误, 正常关闭时关闭通道, 未启动时返回 nil
+func (h *HttpServer) Err() <-chan error {
+	return h.errs
 }
 
 //graceful shutdown  http server wait 5 second
 func (h *HttpServer) GracefulShutdown() {
-	//shutdown signal
-	//sds := make(chan os.Signal)
-	//signal.Notify(sds, syscall.SIGINT, syscall.SIGTERM, syscall.SIGSTOP)
-	//<-sds
 	log.Println("graceful shutdown server ...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := h.Server.Shutdown(ctx); err != nil {
-		log.Fatalf("graceful shutdown server error: %s", err)
+	if err := h.Shutdown(ctx); err != nil {
+		log.Printf("graceful shutdown server error: %s", err)
 	}
 }
 
-
-
-
+//关闭监听并等待处理中的请求完成, ctx 超时时强制关闭剩余连接并返回错误
+func (h *HttpServer) Shutdown(ctx context.Context) error {
+	if h.Server == nil {
+		return nil
+	}
+	err := h.Server.Shutdown(ctx)
+	if err != nil {
+		_ = h.Server.Close()
+	}
+	//Shutdown 已关闭监听
+	h.Listener = nil
+	return err
+}
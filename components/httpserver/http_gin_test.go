@@ -0,0 +1,189 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/httplib"
+	"github.com/jeevi-cao/lego/components/httpserver/middleware"
+	"github.com/jeevi-cao/lego/components/log"
+)
+
+func TestHttpServer_SwitchMiddleware(t *testing.T) {
+	h := NewHttpServer("127.0.0.1", 0, false)
+	header := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Header("X-"+name, "1")
+		}
+	}
+	h.SetSwitchMiddleware("a", true, header("a"))
+	h.SetSwitchMiddleware("b", false, header("b"))
+	h.Engine.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	request := func() http.Header {
+		w := httptest.NewRecorder()
+		h.Engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Header()
+	}
+
+	assert.Equal(t, []string{"a"}, h.GetEnableMiddleware())
+	assert.Equal(t, "1", request().Get("X-a"))
+	assert.Empty(t, request().Get("X-b"))
+
+	h.EnableMiddleware("b")
+	assert.Equal(t, []string{"b"}, h.GetEnableMiddleware())
+	assert.Empty(t, request().Get("X-a"))
+	assert.Equal(t, "1", request().Get("X-b"))
+}
+
+func TestHttpServer_SetListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	h := NewHttpServer("127.0.0.1", 0, false).SetListener(l)
+	h.Engine.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	assert.Nil(t, h.ServerRun())
+	defer h.GracefulShutdown()
+
+	resp, err := http.Get("http://" + l.Addr().String())
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	f, err := h.ListenerFile()
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+}
+
+func TestHttpServer_ListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	//端口被占用
+	h := NewHttpServer("127.0.0.1", port, false)
+	assert.NotNil(t, h.ServerRun())
+	assert.Nil(t, h.Err())
+
+	//服务异常退出
+	h = NewHttpServer("127.0.0.1", 0, true)
+	assert.Nil(t, h.ServerRun())
+	select {
+	case err := <-h.Err():
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("https server without certificate need error")
+	}
+}
+
+func TestHttpServer_Shutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	h := NewHttpServer("127.0.0.1", 0, false).SetListener(l)
+	started := make(chan struct{})
+	h.Engine.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(time.Second)
+		c.String(http.StatusOK, "ok")
+	})
+	assert.Nil(t, h.ServerRun())
+	go func() {
+		_, _ = http.Get("http://" + l.Addr().String() + "/slow")
+	}()
+	<-started
+
+	//请求未完成 超时
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, h.Shutdown(ctx))
+	assert.Nil(t, h.Listener)
+}
+
+func TestHttpServer_RequestLogFields(t *testing.T) {
+	downstream := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream <- r.Header
+	}))
+	defer ts.Close()
+
+	h := NewHttpServer("127.0.0.1", 0, false)
+	h.Engine.Use(middleware.RequestIdMiddleware(""))
+	traceId := "4bf92f3577b34da6a3ce929d0e0736ab"
+	h.Engine.GET("/user/:id", func(c *gin.Context) {
+		fields := log.FromContext(c)
+		assert.Equal(t, "req-1", fields[log.FieldRequestId])
+		assert.Equal(t, "/user/:id", fields[log.FieldRoute])
+		assert.Equal(t, traceId, fields[log.FieldTraceId])
+		assert.NotEmpty(t, fields[log.FieldClientIp])
+		assert.Equal(t, fields, log.FromContext(c.Request.Context()))
+		assert.Equal(t, "req-1", log.WithContext(c, logrus.New()).Data[log.FieldRequestId])
+
+		_, err := httplib.Get(ts.URL).WithContext(c.Request.Context()).String()
+		assert.Nil(t, err)
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/user/1", nil)
+	r.Header.Set("X-Request-Id", "req-1")
+	r.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+	h.Engine.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	header := <-downstream
+	assert.Equal(t, "req-1", header.Get("X-Request-Id"))
+	assert.Equal(t, traceId, header.Get("X-Trace-Id"))
+	assert.Contains(t, header.Get("traceparent"), traceId)
+
+	//自定义 request id 请求头, 下游请求使用相同的请求头
+	h = NewHttpServer("127.0.0.1", 0, false)
+	h.Engine.Use(middleware.RequestIdMiddleware("X-Req-Id"))
+	h.Engine.GET("/", func(c *gin.Context) {
+		_, err := httplib.Get(ts.URL).WithContext(c.Request.Context()).String()
+		assert.Nil(t, err)
+	})
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Req-Id", "req-2")
+	h.Engine.ServeHTTP(httptest.NewRecorder(), r)
+	header = <-downstream
+	assert.Equal(t, "req-2", header.Get("X-Req-Id"))
+	assert.Empty(t, header.Get("X-Request-Id"))
+}
+
+func TestHttpServer_Output(t *testing.T) {
+	defaultWriter, defaultErrorWriter := gin.DefaultWriter, gin.DefaultErrorWriter
+
+	//每个服务的 panic 日志输出到各自的 writer
+	outputs := []*bytes.Buffer{{}, {}}
+	for i, output := range outputs {
+		h := NewHttpServer("127.0.0.1", 0, false).SetOutput(output)
+		message := fmt.Sprintf("panic-%d", i)
+		h.Engine.GET("/", func(c *gin.Context) {
+			panic(message)
+		})
+		w := httptest.NewRecorder()
+		h.Engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+	assert.Contains(t, outputs[0].String(), "panic-0")
+	assert.NotContains(t, outputs[0].String(), "panic-1")
+	assert.Contains(t, outputs[1].String(), "panic-1")
+	assert.NotContains(t, outputs[1].String(), "panic-0")
+
+	assert.Equal(t, defaultWriter, gin.DefaultWriter)
+	assert.Equal(t, defaultErrorWriter, gin.DefaultErrorWriter)
+}
@@ -1,13 +1,27 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jeevi-cao/lego/components/log"
+)
+
+//trace 请求头
+const (
+	TraceParentHeader = "traceparent"
+	TraceIdHeader     = "X-Trace-Id"
 )
 
+//设置 request id, 并把 request id, 客户端ip, 路由, trace id 写入请求 context
+//请求头名称同样写入 context, httplib WithContext 使用相同的请求头传递给下游
+//使用 log.WithContext(c, logger) 或 log.WithContext(c.Request.Context(), logger) 获取携带字段的日志
 func RequestIdMiddleware(requestIdName string) gin.HandlerFunc {
 	if len(requestIdName) == 0 {
-		requestIdName = "X-Request-Id"
+		requestIdName = log.DefaultRequestIdHeader
 	}
 	return func(c *gin.Context) {
 		//判断获取uuid
@@ -19,6 +33,37 @@ func RequestIdMiddleware(requestIdName string) gin.HandlerFunc {
 		c.Request.Header.Set(requestIdName, u)
 		c.Writer.Header().Set(requestIdName, u)
 
+		//请求日志字段
+		traceId := parseTraceId(c)
+		if len(traceId) == 0 {
+			traceId = log.NewTraceId()
+		}
+		fields := logrus.Fields{
+			log.FieldRequestId: u,
+			log.FieldClientIp:  c.ClientIP(),
+			log.FieldRoute:     c.FullPath(),
+			log.FieldTraceId:   traceId,
+			log.FieldSpanId:    log.NewSpanId(),
+		}
+		ctx := log.NewContext(log.WithRequestIdHeader(c.Request.Context(), requestIdName), fields)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(log.ContextKey, log.FromContext(ctx))
+
 		c.Next()
 	}
 }
+
+//从请求头获取 trace id, 优先 W3C traceparent
+func parseTraceId(c *gin.Context) string {
+	//version-traceid-parentid-flags
+	parts := strings.Split(c.GetHeader(TraceParentHeader), "-")
+	if len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return c.GetHeader(TraceIdHeader)
+}
+
+//获取携带请求字段的日志
+func Logger(c *gin.Context, logger *logrus.Logger) *logrus.Entry {
+	return log.WithContext(c, logger)
+}
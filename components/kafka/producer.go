@@ -4,12 +4,16 @@ import (
 	"fmt"
 	"github.com/Shopify/sarama"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Producer struct {
 	config  *sarama.Config
 	setting *ProducerSetting
+	//SendMessages 复用的连接
+	syncProducer sarama.SyncProducer
+	mutex        sync.Mutex
 }
 
 type ProducerSetting struct {
@@ -23,7 +27,7 @@ type ProducerSetting struct {
 
 func NewKafkaProducer(producerSetting *ProducerSetting) *Producer {
 	config := buildProducerConfig(producerSetting)
-	return &Producer{config, producerSetting}
+	return &Producer{config: config, setting: producerSetting}
 }
 
 func buildProducerConfig(producerSetting *ProducerSetting) *sarama.Config {
@@ -75,3 +79,41 @@ func (kafkaProducer *Producer) sendMsgASync(topic string, key string, value stri
 		return fail
 	}
 }
+
+//批量同步发送, 复用连接, 发送失败时关闭连接 下次发送重新连接
+func (kafkaProducer *Producer) SendMessages(topic string, values [][]byte) error {
+	defer kafkaProducer.mutex.Unlock()
+	kafkaProducer.mutex.Lock()
+	if kafkaProducer.syncProducer == nil {
+		//同步发送需要返回成功
+		config := *kafkaProducer.config
+		config.Producer.Return.Successes = true
+		syncProducer, err := sarama.NewSyncProducer(kafkaProducer.setting.HostArr, &config)
+		if err != nil {
+			return err
+		}
+		kafkaProducer.syncProducer = syncProducer
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(values))
+	for _, value := range values {
+		msgs = append(msgs, &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)})
+	}
+	if err := kafkaProducer.syncProducer.SendMessages(msgs); err != nil {
+		_ = kafkaProducer.syncProducer.Close()
+		kafkaProducer.syncProducer = nil
+		return err
+	}
+	return nil
+}
+
+//关闭 SendMessages 复用的连接
+func (kafkaProducer *Producer) Close() error {
+	defer kafkaProducer.mutex.Unlock()
+	kafkaProducer.mutex.Lock()
+	if kafkaProducer.syncProducer == nil {
+		return nil
+	}
+	err := kafkaProducer.syncProducer.Close()
+	kafkaProducer.syncProducer = nil
+	return err
+}
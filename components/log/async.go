@@ -0,0 +1,170 @@
+package log
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//异步写入
+//	日志写入内存队列后立即返回, 后台协程批量写入文件, 避免磁盘阻塞影响请求耗时
+//	队列满时按策略处理:
+//	block        阻塞等待
+//	drop_oldest  丢弃队列中最早的日志
+//	drop_newest  丢弃当前写入的日志
+//	后台协程定时刷新缓冲, Flush 等待已写入的日志全部落盘
+const (
+	AsyncBlock      = "block"
+	AsyncDropOldest = "drop_oldest"
+	AsyncDropNewest = "drop_newest"
+)
+
+const (
+	//默认队列长度 单位:条
+	DefaultAsyncQueueSize = 1024
+	//默认刷新间隔
+	DefaultAsyncFlushInterval = time.Second
+	//写入文件的缓冲大小
+	asyncBufferSize = 64 * 1024
+)
+
+var ErrAsyncClosed = errors.New("log async writer closed")
+
+type asyncWriter struct {
+	w        io.Writer
+	queue    chan []byte
+	policy   string
+	interval time.Duration
+	dropped  uint64
+	flush    chan chan error
+	done     chan struct{}
+	finished chan struct{}
+	closed   bool
+	//写入与关闭互斥
+	mutex sync.RWMutex
+}
+
+func newAsyncWriter(w io.Writer, size int, policy string, interval time.Duration) *asyncWriter {
+	if size <= 0 {
+		size = DefaultAsyncQueueSize
+	}
+	if interval <= 0 {
+		interval = DefaultAsyncFlushInterval
+	}
+	if policy != AsyncDropOldest && policy != AsyncDropNewest {
+		policy = AsyncBlock
+	}
+	a := &asyncWriter{
+		w:        w,
+		queue:    make(chan []byte, size),
+		policy:   policy,
+		interval: interval,
+		flush:    make(chan chan error),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	go a.loop()
+	return a
+}
+
+//写入队列, 调用方可能复用 p, 需要复制
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	defer a.mutex.RUnlock()
+	a.mutex.RLock()
+	if a.closed {
+		return 0, ErrAsyncClosed
+	}
+	b := append([]byte{}, p...)
+	switch a.policy {
+	case AsyncDropNewest:
+		select {
+		case a.queue <- b:
+		default:
+			atomic.AddUint64(&a.dropped, 1)
+		}
+	case AsyncDropOldest:
+		for {
+			select {
+			case a.queue <- b:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-a.queue:
+				atomic.AddUint64(&a.dropped, 1)
+			default:
+			}
+		}
+	default:
+		a.queue <- b
+	}
+	return len(p), nil
+}
+
+func (a *asyncWriter) loop() {
+	defer close(a.finished)
+	buf := bufio.NewWriterSize(a.w, asyncBufferSize)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	//写入队列中已有的日志并刷新
+	drain := func() error {
+		for {
+			select {
+			case b := <-a.queue:
+				_, _ = buf.Write(b)
+			default:
+				return buf.Flush()
+			}
+		}
+	}
+	for {
+		select {
+		case b := <-a.queue:
+			_, _ = buf.Write(b)
+		case <-ticker.C:
+			_ = buf.Flush()
+		case ch := <-a.flush:
+			ch <- drain()
+		case <-a.done:
+			_ = drain()
+			return
+		}
+	}
+}
+
+//等待已写入的日志落盘
+func (a *asyncWriter) Flush() error {
+	defer a.mutex.RUnlock()
+	a.mutex.RLock()
+	if a.closed {
+		return nil
+	}
+	ch := make(chan error, 1)
+	a.flush <- ch
+	return <-ch
+}
+
+//丢弃的日志条数
+func (a *asyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+//落盘后关闭底层writer
+func (a *asyncWriter) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mutex.Unlock()
+	close(a.done)
+	<-a.finished
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
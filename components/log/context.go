@@ -0,0 +1,124 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+//请求级别日志字段
+//usage:
+//
+//	//http 请求中间件 middleware.RequestIdMiddleware 已写入请求字段
+//	func handler(c *gin.Context) {
+//		log.WithContext(c, logger).Info("handle")
+//		//下游请求携带相同的 request id 及 trace id
+//		_, _ = httplib.Get(url).WithContext(c.Request.Context()).String()
+//	}
+//
+//	//非 http 请求时自行生成
+//	ctx := log.NewContext(context.Background(), logrus.Fields{log.FieldTraceId: log.NewTraceId()})
+//	log.WithContext(ctx, logger).Info("job")
+
+const (
+	FieldRequestId = "request_id"
+	FieldClientIp  = "client_ip"
+	FieldRoute     = "route"
+	FieldTraceId   = "trace_id"
+	FieldSpanId    = "span_id"
+	FieldJob       = "job"
+)
+
+//gin.Context 中保存字段的 key, gin.Context.Value 只支持字符串 key
+const ContextKey = "lego.log.fields"
+
+//request id 请求头默认名称
+const DefaultRequestIdHeader = "X-Request-Id"
+
+type fieldsKey struct{}
+
+type requestIdHeaderKey struct{}
+
+//返回携带日志字段的 context, 与已有字段合并, 相同字段覆盖
+func NewContext(ctx context.Context, fields logrus.Fields) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	merged := FromContext(ctx)
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+//获取 context 中的日志字段, 返回副本, 不存在时返回空字段
+func FromContext(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if ctx == nil {
+		return fields
+	}
+	stored, ok := ctx.Value(fieldsKey{}).(logrus.Fields)
+	if !ok {
+		stored, _ = ctx.Value(ContextKey).(logrus.Fields)
+	}
+	for k, v := range stored {
+		fields[k] = v
+	}
+	return fields
+}
+
+//返回携带 context 日志字段的 Entry
+func WithContext(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	entry := logrus.NewEntry(logger).WithFields(FromContext(ctx))
+	if ctx != nil {
+		entry = entry.WithContext(ctx)
+	}
+	return entry
+}
+
+//返回携带 request id 请求头名称的 context, 下游请求使用相同的请求头传递 request id
+func WithRequestIdHeader(ctx context.Context, name string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, requestIdHeaderKey{}, name)
+}
+
+//获取 context 中的 request id 请求头名称, 不存在时返回 DefaultRequestIdHeader
+func RequestIdHeader(ctx context.Context) string {
+	if ctx != nil {
+		if name, ok := ctx.Value(requestIdHeaderKey{}).(string); ok && len(name) > 0 {
+			return name
+		}
+	}
+	return DefaultRequestIdHeader
+}
+
+//获取 context 中的字符串字段
+func FieldString(ctx context.Context, key string) string {
+	s, _ := FromContext(ctx)[key].(string)
+	return s
+}
+
+//生成 trace id, 16字节 hex
+func NewTraceId() string {
+	return randomHex(16)
+}
+
+//生成 span id, 8字节 hex
+func NewSpanId() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//返回携带 context 日志字段的 Entry
+func (l *Log) WithContext(ctx context.Context) *logrus.Entry {
+	return WithContext(ctx, l.Logger)
+}
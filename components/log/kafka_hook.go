@@ -0,0 +1,286 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jeevi-cao/lego/components/kafka"
+)
+
+//kafka 日志
+//usage:
+//
+//	c := Setting{
+//		...
+//		Kafka: KafkaSetting{
+//			Enable: true,
+//			Hosts:  "127.0.0.1:9092",
+//			Topic:  "app_log",
+//			Levels: []string{"warn", "error"},
+//		},
+//	}
+//	logger, err := NewLog(c)
+//
+//	日志格式化后写入内存队列, 调用方不阻塞, 队列满时丢弃并计数
+//	后台按条数或间隔批量发送, 发送失败的日志写入本地文件, 之后一段时间内不再尝试发送直接写入本地文件
+
+const (
+	//默认批量条数
+	DefaultKafkaBatchSize = 100
+	//默认批量间隔
+	DefaultKafkaFlushInterval = time.Second
+	//默认队列长度 单位:条
+	DefaultKafkaQueueSize = 10000
+	//发送失败后暂停发送的时间
+	kafkaRetryInterval = 10 * time.Second
+)
+
+//kafka 日志配置
+type KafkaSetting struct {
+	Enable        bool
+	Hosts         string        //broker 地址, 逗号分隔
+	Topic         string        //发送的 topic
+	Levels        []string      //发送的级别, 默认全部
+	Format        string        //json 或 ydLog, 默认与文件日志相同
+	BatchSize     int           //批量条数
+	FlushInterval time.Duration //批量间隔
+	QueueSize     int           //队列长度 单位:条
+	FallbackFile  string        //发送失败时写入的本地文件, 默认为日志文件名加 .kafka
+	RequiredAcks  int           //-1 全部副本 0 不等待 1 leader
+	Timeout       int           //发送超时 单位:秒
+}
+
+//批量发送接口, 默认使用 kafka.Producer
+type KafkaSender interface {
+	SendMessages(topic string, values [][]byte) error
+}
+
+//发送日志到 kafka 的 hook
+type KafkaHook struct {
+	setting   KafkaSetting
+	sender    KafkaSender
+	formatter logrus.Formatter
+	levels    []logrus.Level
+	queue     chan []byte
+	dropped   uint64
+	//最近一次发送失败时间
+	failedAt time.Time
+	flush    chan chan error
+	done     chan struct{}
+	finished chan struct{}
+	closed   bool
+	//写入与关闭互斥
+	mutex sync.RWMutex
+}
+
+//实例化, sender 为空时按配置创建 kafka.Producer, 级别名称不合法时返回错误
+func NewKafkaHook(setting KafkaSetting, sender KafkaSender, formatter logrus.Formatter) (*KafkaHook, error) {
+	levels := logrus.AllLevels
+	if len(setting.Levels) > 0 {
+		levels = make([]logrus.Level, 0, len(setting.Levels))
+		for _, level := range setting.Levels {
+			lv, err := logrus.ParseLevel(level)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("kafka log level error:%s", err.Error()))
+			}
+			levels = append(levels, lv)
+		}
+	}
+	if setting.BatchSize <= 0 {
+		setting.BatchSize = DefaultKafkaBatchSize
+	}
+	if setting.FlushInterval <= 0 {
+		setting.FlushInterval = DefaultKafkaFlushInterval
+	}
+	if setting.QueueSize <= 0 {
+		setting.QueueSize = DefaultKafkaQueueSize
+	}
+	if sender == nil {
+		sender = kafka.NewKafkaProducer(&kafka.ProducerSetting{
+			Hosts:        setting.Hosts,
+			Topic:        setting.Topic,
+			RequiredAcks: setting.RequiredAcks,
+			Timeout:      setting.Timeout,
+		})
+	}
+	if formatter == nil {
+		formatter = &logrus.JSONFormatter{}
+	}
+	h := &KafkaHook{
+		setting:   setting,
+		sender:    sender,
+		formatter: formatter,
+		levels:    levels,
+		queue:     make(chan []byte, setting.QueueSize),
+		flush:     make(chan chan error),
+		done:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+	go h.loop()
+	return h, nil
+}
+
+//按日志配置创建, 未开启时返回 nil
+func newKafkaHookFromSetting(c *Setting) (*KafkaHook, error) {
+	if c == nil || !c.Kafka.Enable || len(c.Kafka.Hosts) == 0 || len(c.Kafka.Topic) == 0 {
+		return nil, nil
+	}
+	setting := c.Kafka
+	if len(setting.FallbackFile) == 0 && len(c.Path) > 0 && len(c.FileName) > 0 {
+		setting.FallbackFile = path.Join(c.Path, c.FileName+".kafka")
+	}
+	format := setting.Format
+	if len(format) == 0 {
+		format = c.Format
+	}
+	return NewKafkaHook(setting, nil, newFormatter(format, c))
+}
+
+func (h *KafkaHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+//格式化后写入队列, 队列满时丢弃
+func (h *KafkaHook) Fire(entry *logrus.Entry) error {
+	defer h.mutex.RUnlock()
+	h.mutex.RLock()
+	if h.closed {
+		return nil
+	}
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	select {
+	case h.queue <- append([]byte{}, b...):
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+	return nil
+}
+
+func (h *KafkaHook) loop() {
+	defer close(h.finished)
+	ticker := time.NewTicker(h.setting.FlushInterval)
+	defer ticker.Stop()
+	batch := make([][]byte, 0, h.setting.BatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := h.send(batch)
+		batch = make([][]byte, 0, h.setting.BatchSize)
+		return err
+	}
+	//发送队列中已有的日志
+	drain := func() error {
+		var first error
+		for {
+			select {
+			case b := <-h.queue:
+				batch = append(batch, b)
+				if len(batch) >= h.setting.BatchSize {
+					if err := send(); err != nil && first == nil {
+						first = err
+					}
+				}
+			default:
+				if err := send(); err != nil && first == nil {
+					first = err
+				}
+				return first
+			}
+		}
+	}
+	for {
+		select {
+		case b := <-h.queue:
+			batch = append(batch, b)
+			if len(batch) >= h.setting.BatchSize {
+				_ = send()
+			}
+		case <-ticker.C:
+			_ = send()
+		case ch := <-h.flush:
+			ch <- drain()
+		case <-h.done:
+			_ = drain()
+			return
+		}
+	}
+}
+
+//发送失败或暂停发送期间写入本地文件, 只返回写入本地文件的错误
+func (h *KafkaHook) send(batch [][]byte) error {
+	if time.Since(h.failedAt) >= kafkaRetryInterval {
+		err := h.sender.SendMessages(h.setting.Topic, batch)
+		if err == nil {
+			return nil
+		}
+		h.failedAt = time.Now()
+		log.Printf("[log] kafka send error:%s, write to fallback file", err)
+	}
+	return h.writeFallback(batch)
+}
+
+func (h *KafkaHook) writeFallback(batch [][]byte) error {
+	if len(h.setting.FallbackFile) == 0 {
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		return nil
+	}
+	f, err := os.OpenFile(h.setting.FallbackFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		atomic.AddUint64(&h.dropped, uint64(len(batch)))
+		return err
+	}
+	defer f.Close()
+	for _, b := range batch {
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//等待队列中的日志发送完成
+func (h *KafkaHook) Flush() error {
+	defer h.mutex.RUnlock()
+	h.mutex.RLock()
+	if h.closed {
+		return nil
+	}
+	ch := make(chan error, 1)
+	h.flush <- ch
+	return <-ch
+}
+
+//丢弃的日志条数
+func (h *KafkaHook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+//发送队列中的日志后关闭连接
+func (h *KafkaHook) Close() error {
+	h.mutex.Lock()
+	if h.closed {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mutex.Unlock()
+	close(h.done)
+	<-h.finished
+	if c, ok := h.sender.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -8,9 +8,9 @@ import (
 	"log"
 	"os"
 	"path"
+	"sync"
 	"time"
 
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
 
@@ -41,6 +41,20 @@ type Log struct {
 	//初始化日志句柄
 	Logger *logrus.Logger
 	Writer  io.Writer
+
+	//重新打开时关闭的文件
+	closers []io.Closer
+	//已关闭的异步writer丢弃的日志条数
+	dropped uint64
+
+	//非临时修改的日志级别
+	baseLevel logrus.Level
+	//配置的日志级别, 配置未变化时不重新设置
+	configLevel string
+	//临时级别恢复定时器
+	revertTimer *time.Timer
+	revertAt    time.Time
+	mutex       sync.Mutex
 }
 
 //日志配置信息
@@ -56,83 +70,144 @@ type Setting struct {
 	ReportCaller    bool          //是否打印调用栈位置 行号
 	ReportHostIp    bool          //是否打印host ip
 	ReportShortFile bool          //文件路径短写
+	RotationSize    int64         //按大小切割 单位:MB
+	Compress        bool          //gzip 压缩已切割文件
+	MaxBackups      int           //保留已切割文件数量
+	MaxTotalSize    int64         //日志文件总大小上限 单位:MB
+
+	Async              bool          //异步写入文件
+	AsyncQueueSize     int           //异步队列长度 单位:条
+	AsyncPolicy        string        //队列满时策略 block drop_oldest drop_newest
+	AsyncFlushInterval time.Duration //异步刷新间隔
+
+	Kafka  KafkaSetting  //发送到 kafka
+	Syslog SyslogSetting //发送到 syslog
+	Redact RedactSetting //敏感信息脱敏
 }
 
 //实例化Log
 func NewLog(setting Setting) (*Log, error) {
-	h, w, err := InitLogrus(&setting)
+	h, w, closers, err := initLogrus(&setting)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("log init logrus error err:%s", err.Error()))
 	}
-	return &Log{Setting: &setting, Logger: h, Writer: w}, nil
+	//文件日志使用可替换目标的writer, 重新打开后已引用 Writer 的使用方写入新文件
+	if len(setting.Path) > 0 {
+		w = &switchWriter{w: w}
+	}
+	return &Log{Setting: &setting, Logger: h, Writer: w, closers: closers, baseLevel: h.GetLevel(), configLevel: setting.Level}, nil
+}
+
+//按新配置重新打开日志文件, Logger 及 Writer 不变, 已持有的使用方写入新文件
+//日志级别不变, 由 SetLevel 修改
+func (l *Log) Reopen(setting Setting) error {
+	h, w, closers, err := initLogrus(&setting)
+	if err != nil {
+		return errors.New(fmt.Sprintf("log reopen error err:%s", err.Error()))
+	}
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+
+	//替换后不再有写入旧文件的hook
+	l.Logger.ReplaceHooks(h.Hooks)
+	l.Logger.SetOutput(h.Out)
+	if sw, ok := l.Writer.(*switchWriter); ok {
+		sw.swap(w)
+	} else {
+		l.Writer = &switchWriter{w: w}
+	}
+	for _, c := range l.closers {
+		if d, ok := c.(dropper); ok {
+			l.dropped += d.Dropped()
+		}
+		_ = c.Close()
+	}
+	l.closers = closers
+	l.Setting = &setting
+	return nil
+}
+
+//可替换目标的writer, 写入期间不会被关闭
+type switchWriter struct {
+	w     io.Writer
+	mutex sync.RWMutex
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	defer s.mutex.RUnlock()
+	s.mutex.RLock()
+	return s.w.Write(p)
+}
+
+//等待写入完成后替换
+func (s *switchWriter) swap(w io.Writer) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.w = w
 }
 
 //进行初始化
 func InitLogrus(c *Setting) (*logrus.Logger, io.Writer, error) {
+	l, w, _, err := initLogrus(c)
+	return l, w, err
+}
+
+//初始化, 返回需要关闭的文件
+func initLogrus(c *Setting) (*logrus.Logger, io.Writer, []io.Closer, error) {
 	l := logrus.New()
+	//脱敏需要在其它 hook 之前
+	rh, err := newRedactHookFromSetting(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if rh != nil {
+		l.Hooks.Add(rh)
+	}
+	if c != nil {
+		//设置日志级别, 输出到标准输出及 syslog 时同样生效
+		l.SetLevel(parseLevel(c.Level))
+		//判断是否有error
+		if c.ReportCaller {
+			l.SetReportCaller(c.ReportCaller)
+		}
+	}
 	//如果未设置path filename 直接返回
 	if c == nil || len(c.Path) == 0 {
 		l.SetOutput(os.Stdout)
-		return l, os.Stdout, nil
+		closers, sh, err := addOutputHooks(l, c)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		//只输出到 syslog
+		if sh != nil {
+			if devW, err := getDevNullWriter(); err == nil {
+				l.SetOutput(devW)
+			}
+			return l, sh, closers, nil
+		}
+		return l, os.Stdout, closers, nil
 	}
 
 	basePath := path.Join(c.Path, c.FileName)
-	writer, err := rotatelogs.New(
-		basePath+c.Split,
-		rotatelogs.WithLinkName(basePath),                 //生成软连接, 指向最新日志文件
-		rotatelogs.WithMaxAge(c.LifeTime*time.Hour),       //文件最大保存时间 单位:时间
-		rotatelogs.WithRotationTime(c.Rotation*time.Hour), //文件切割时间时间
-	)
+	writer, closers, err := newFileWriter(basePath, c)
 	if err != nil || l == nil {
 		log.Printf("failed to create rotatelogs err:%s", err)
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	//错误文件地址
 	var errWriter io.Writer
 	if len(c.ErrFileName) > 0 {
-		errPath := path.Join(c.Path, c.ErrFileName)
-		ew, err := rotatelogs.New(
-			errPath+c.Split,
-			rotatelogs.WithLinkName(errPath),                  //生成软连接, 指向最新日志文件
-			rotatelogs.WithMaxAge(c.LifeTime*time.Hour),       //文件最大保存时间 单位:时间
-			rotatelogs.WithRotationTime(c.Rotation*time.Hour), //文件切割时间时间
-		)
+		ew, errClosers, err := newFileWriter(path.Join(c.Path, c.ErrFileName), c)
 		if err != nil {
 			log.Printf("failed to create error rotatelogs err:%s", err)
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
+		closers = append(closers, errClosers...)
 		errWriter = io.MultiWriter(writer, ew)
 	} else {
 		errWriter = writer
 	}
 
-	//设置日志级别
-	switch c.Level {
-	case "trace":
-		l.SetLevel(logrus.TraceLevel)
-		break
-	case "debug":
-		l.SetLevel(logrus.DebugLevel)
-		break
-	case "info":
-		l.SetLevel(logrus.InfoLevel)
-		break
-	case "warn":
-		l.SetLevel(logrus.WarnLevel)
-		break
-	case "error":
-		l.SetLevel(logrus.ErrorLevel)
-		break
-	case "fatal":
-		l.SetLevel(logrus.FatalLevel)
-		break
-	case "panic":
-		l.SetLevel(logrus.PanicLevel)
-		break
-	default:
-		l.SetLevel(logrus.InfoLevel)
-	}
-
 	//聚合文件地址
 	hook := lfshook.NewHook(
 		lfshook.WriterMap{
@@ -146,44 +221,187 @@ func InitLogrus(c *Setting) (*logrus.Logger, io.Writer, error) {
 		&logrus.JSONFormatter{},
 	)
 
-	//判断是否有error
-	if c.ReportCaller {
-		l.SetReportCaller(c.ReportCaller)
+	hook.SetFormatter(newFormatter(c.Format, c))
+	l.Hooks.Add(hook)
+	hookClosers, _, err := addOutputHooks(l, c)
+	if err != nil {
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+		return nil, nil, nil, err
+	}
+	closers = append(closers, hookClosers...)
+	//将logrus 指定到 dev
+	if devW, err := getDevNullWriter(); err == nil {
+		l.SetOutput(devW)
+	}
+	return l, writer, closers, nil
+}
+
+//添加 kafka syslog 输出, 返回需要关闭的对象
+func addOutputHooks(l *logrus.Logger, c *Setting) ([]io.Closer, *SyslogHook, error) {
+	var closers []io.Closer
+	sh, err := newSyslogHookFromSetting(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sh != nil {
+		l.Hooks.Add(sh)
+		closers = append(closers, sh)
+	}
+	kh, err := newKafkaHookFromSetting(c)
+	if err != nil {
+		for _, closer := range closers {
+			_ = closer.Close()
+		}
+		return nil, nil, err
 	}
+	if kh != nil {
+		l.Hooks.Add(kh)
+		closers = append(closers, kh)
+	}
+	return closers, sh, nil
+}
 
-	switch c.Format {
+//按格式名称创建格式化, 未知格式使用 json
+func newFormatter(format string, c *Setting) logrus.Formatter {
+	switch format {
 	case "json":
-		hook.SetFormatter(&logrus.JSONFormatter{})
-		break
+		return &logrus.JSONFormatter{}
 	case "text":
-		hook.SetFormatter(&logrus.TextFormatter{})
-		break
+		return &logrus.TextFormatter{}
 	case "ydLog":
 		//Host Ip
 		ip, _ := util.GetLocalIp()
-		hook.SetFormatter(&YdLogFormatter{
+		return &YdLogFormatter{
 			TimestampFormat: "2006-01-02 15:04:05,000",
 			HostIp:          ip,
 			ReportCaller:    c.ReportCaller,
 			ReportHostIp:    c.ReportHostIp,
 			ReportShortFile: c.ReportShortFile,
-		})
-		break
+		}
 	default:
-		hook.SetFormatter(&logrus.JSONFormatter{})
+		return &logrus.JSONFormatter{}
 	}
-	l.Hooks.Add(hook)
-	//将logrus 指定到 dev
-	if devW, err := getDevNullWriter(); err == nil {
-		l.SetOutput(devW)
+}
+
+//异步写入 kafka 发送等需要落盘的对象
+type flusher interface {
+	Flush() error
+}
+
+//统计丢弃日志条数的对象
+type dropper interface {
+	Dropped() uint64
+}
+
+//等待异步写入及 kafka 发送的日志落盘, 未开启时直接返回
+func (l *Log) Flush() error {
+	l.mutex.Lock()
+	closers := append([]io.Closer{}, l.closers...)
+	l.mutex.Unlock()
+	var first error
+	for _, c := range closers {
+		if f, ok := c.(flusher); ok {
+			if err := f.Flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+//异步队列及 kafka 队列满时丢弃的日志条数
+func (l *Log) Dropped() uint64 {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+	dropped := l.dropped
+	for _, c := range l.closers {
+		if d, ok := c.(dropper); ok {
+			dropped += d.Dropped()
+		}
 	}
-	return l, writer, nil
+	return dropped
 }
 
 func (l *Log) GetLogger() *logrus.Logger {
 	return l.Logger
 }
 
+//运行时修改日志级别
+//duration > 0 为临时修改, 到期恢复为非临时修改的级别; duration <= 0 为永久修改
+func (l *Log) SetLevel(level string, duration time.Duration) error {
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return errors.New(fmt.Sprintf("log set level error:%s", err.Error()))
+	}
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+
+	if l.revertTimer != nil {
+		l.revertTimer.Stop()
+		l.revertTimer = nil
+		l.revertAt = time.Time{}
+	}
+	l.Logger.SetLevel(lv)
+	if duration <= 0 {
+		l.baseLevel = lv
+		return nil
+	}
+	l.revertAt = time.Now().Add(duration)
+	l.revertTimer = time.AfterFunc(duration, l.revert)
+	return nil
+}
+
+//按配置设置级别, 与上次配置的级别相同时不修改, 保留临时修改的级别及恢复定时器
+//返回级别是否变化
+func (l *Log) ApplyLevel(level string) (bool, error) {
+	l.mutex.Lock()
+	same := level == l.configLevel
+	l.mutex.Unlock()
+	if same {
+		return false, nil
+	}
+	if err := l.SetLevel(level, 0); err != nil {
+		return false, err
+	}
+	l.mutex.Lock()
+	l.configLevel = level
+	l.mutex.Unlock()
+	return true, nil
+}
+
+//恢复临时修改的级别
+func (l *Log) revert() {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+
+	l.Logger.SetLevel(l.baseLevel)
+	l.revertTimer = nil
+	l.revertAt = time.Time{}
+}
+
+//当前日志级别
+func (l *Log) GetLevel() string {
+	return l.Logger.GetLevel().String()
+}
+
+//临时级别恢复时间, 没有临时修改时返回零值
+func (l *Log) GetRevertAt() time.Time {
+	defer l.mutex.Unlock()
+	l.mutex.Lock()
+	return l.revertAt
+}
+
+//日志级别, 未知级别使用info
+func parseLevel(level string) logrus.Level {
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+	return lv
+}
+
 func getDevNullWriter() (io.Writer, error) {
 	src, err := os.OpenFile(os.DevNull, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
 	return bufio.NewWriter(src), err
@@ -1,18 +1,26 @@
 package log
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
 //初始化logger
 func initLogger(t *testing.T) *Log {
-	dir, _ := os.Getwd()
-	var path = dir + "/../../test/logs/"
 	c := Setting{
-		Path:         path,
+		Path:         t.TempDir(),
 		FileName:     "app.log",
 		Level:        "trace",
 		Split:        ".%Y%m%d%H",
@@ -43,3 +51,442 @@ func TestLog_Info(t *testing.T) {
 	logger := initLogger(t)
 	logger.Logger.Info("info")
 }
+
+func TestLog_SetLevel(t *testing.T) {
+	logger, _ := NewLog(Setting{})
+	assert.Equal(t, "info", logger.GetLevel())
+
+	assert.NotNil(t, logger.SetLevel("unknown", 0), "unknown level need error")
+
+	assert.Nil(t, logger.SetLevel("warn", 0))
+	assert.Equal(t, "warning", logger.GetLevel())
+
+	//临时修改 到期恢复
+	assert.Nil(t, logger.SetLevel("debug", 50*time.Millisecond))
+	assert.Equal(t, "debug", logger.GetLevel())
+	assert.False(t, logger.GetRevertAt().IsZero())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "warning", logger.GetLevel(), "need revert")
+	assert.True(t, logger.GetRevertAt().IsZero())
+}
+
+func TestLog_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	c := Setting{Path: dir, FileName: "a.log", Level: "info", Split: ".%Y%m%d", Format: "text"}
+	logger, err := NewLog(c)
+	assert.Nil(t, err)
+	writer := logger.Writer
+	assert.Nil(t, logger.SetLevel("debug", 0))
+
+	c.FileName = "b.log"
+	assert.Nil(t, logger.Reopen(c))
+	assert.Equal(t, "debug", logger.GetLevel(), "reopen need keep level")
+	logger.Logger.Info("after reopen")
+	_, _ = writer.Write([]byte("writer after reopen\n"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "b.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "after reopen")
+	assert.Contains(t, string(data), "writer after reopen")
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "a.log"))
+	assert.NotContains(t, string(data), "after reopen")
+}
+
+func TestWithContext(t *testing.T) {
+	assert.Empty(t, FromContext(nil))
+
+	ctx := NewContext(context.Background(), logrus.Fields{FieldRequestId: "a", FieldTraceId: "t"})
+	ctx = NewContext(ctx, logrus.Fields{FieldRequestId: "b"})
+	fields := FromContext(ctx)
+	assert.Equal(t, logrus.Fields{FieldRequestId: "b", FieldTraceId: "t"}, fields)
+	//返回副本
+	fields[FieldRoute] = "/"
+	assert.Empty(t, FieldString(ctx, FieldRoute))
+
+	logger, _ := NewLog(Setting{})
+	entry := logger.WithContext(ctx)
+	assert.Equal(t, "b", entry.Data[FieldRequestId])
+	assert.Equal(t, ctx, entry.Context)
+	assert.Equal(t, 32, len(NewTraceId()))
+}
+
+//阻塞写入的writer
+type blockWriter struct {
+	release chan struct{}
+	data    []byte
+	mutex   sync.Mutex
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	<-b.release
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *blockWriter) String() string {
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	return string(b.data)
+}
+
+func TestAsyncWriter_Policy(t *testing.T) {
+	for _, policy := range []string{AsyncDropOldest, AsyncDropNewest} {
+		w := &blockWriter{release: make(chan struct{})}
+		a := newAsyncWriter(w, 2, policy, time.Millisecond)
+		//后台协程取出第一条后定时刷新, 阻塞在底层写入
+		for i := 0; i < 6; i++ {
+			_, err := a.Write([]byte(strconv.Itoa(i)))
+			assert.Nil(t, err)
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(t, a.Dropped() > 0, policy+" need drop")
+		close(w.release)
+		assert.Nil(t, a.Flush())
+		if policy == AsyncDropOldest {
+			assert.True(t, strings.HasSuffix(w.String(), "45"), w.String())
+		} else {
+			assert.False(t, strings.Contains(w.String(), "5"), w.String())
+		}
+		assert.Nil(t, a.Close())
+		_, err := a.Write([]byte("x"))
+		assert.Equal(t, ErrAsyncClosed, err)
+	}
+}
+
+func TestLog_Async(t *testing.T) {
+	dir := t.TempDir()
+	c := Setting{Path: dir, FileName: "a.log", Level: "info", Split: ".%Y%m%d", Format: "text",
+		Async: true, AsyncFlushInterval: time.Hour}
+	logger, err := NewLog(c)
+	assert.Nil(t, err)
+	logger.Logger.Info("async")
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "a.log"))
+	assert.NotContains(t, string(data), "async", "need buffered")
+
+	assert.Nil(t, logger.Flush())
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "a.log"))
+	assert.Contains(t, string(data), "async")
+	assert.Equal(t, uint64(0), logger.Dropped())
+
+	//重新打开时旧文件落盘
+	logger.Logger.Info("before reopen")
+	c.FileName = "b.log"
+	assert.Nil(t, logger.Reopen(c))
+	data, _ = ioutil.ReadFile(filepath.Join(dir, "a.log"))
+	assert.Contains(t, string(data), "before reopen")
+}
+
+func TestRetention_Cleanup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		name := filepath.Join(dir, "app.log.2021010"+strconv.Itoa(i))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(strings.Repeat("x", 1000)), 0644))
+		modTime := now.Add(time.Duration(i-5) * time.Hour)
+		assert.Nil(t, os.Chtimes(name, modTime, modTime))
+	}
+	r := newRetention(filepath.Join(dir, "app.log.%Y%m%d"), true, 3, 0)
+	defer r.Close()
+	r.current = func() string { return filepath.Join(dir, "app.log.20210105") }
+	r.cleanup()
+	matches, _ := filepath.Glob(filepath.Join(dir, "app.log.*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app.log.20210102.gz"),
+		filepath.Join(dir, "app.log.20210103.gz"),
+		filepath.Join(dir, "app.log.20210104.gz"),
+		filepath.Join(dir, "app.log.20210105"),
+	}, matches)
+	info, _ := os.Stat(filepath.Join(dir, "app.log.20210104.gz"))
+	assert.Equal(t, now.Add(-time.Hour).Unix(), info.ModTime().Unix(), "need keep mod time")
+
+	//总大小超出时删除最早的
+	r.maxBackups = 0
+	r.maxTotalSize = 1000 + info.Size()
+	r.cleanup()
+	matches, _ = filepath.Glob(filepath.Join(dir, "app.log.*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app.log.20210104.gz"),
+		filepath.Join(dir, "app.log.20210105"),
+	}, matches)
+}
+
+func TestLog_RotationSize(t *testing.T) {
+	dir := t.TempDir()
+	c := Setting{Path: dir, FileName: "a.log", Level: "info", Split: ".%Y%m%d", Format: "text",
+		RotationSize: 1, Compress: true, MaxBackups: 2}
+	logger, err := NewLog(c)
+	assert.Nil(t, err)
+	line := strings.Repeat("x", 600*1024)
+	for i := 0; i < 8; i++ {
+		logger.Logger.Info(line)
+	}
+	var matches []string
+	for i := 0; i < 100; i++ {
+		matches, _ = filepath.Glob(filepath.Join(dir, "a.log.*.gz"))
+		if len(matches) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Nil(t, logger.Reopen(c))
+	assert.Equal(t, 2, len(matches), "need compress and keep max backups")
+	plain, _ := filepath.Glob(filepath.Join(dir, "a.log.*[0-9]"))
+	assert.Equal(t, 1, len(plain), "only current file uncompressed")
+}
+
+//记录发送的日志, fail 时返回错误
+type testSender struct {
+	fail bool
+	//非空时发送前通知 并阻塞等待
+	sending chan struct{}
+	release chan struct{}
+	values  []string
+	mutex   sync.Mutex
+}
+
+func (s *testSender) SendMessages(topic string, values [][]byte) error {
+	if s.sending != nil {
+		s.sending <- struct{}{}
+		<-s.release
+	}
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if s.fail {
+		return errors.New("broker unavailable")
+	}
+	for _, v := range values {
+		s.values = append(s.values, topic+":"+string(v))
+	}
+	return nil
+}
+
+func TestKafkaHook(t *testing.T) {
+	sender := &testSender{}
+	hook, err := NewKafkaHook(KafkaSetting{Topic: "log", Levels: []string{"warning", "error"}, BatchSize: 2, FlushInterval: time.Hour},
+		sender, &logrus.TextFormatter{DisableTimestamp: true})
+	assert.Nil(t, err)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+	logger.Error("flush")
+	assert.Nil(t, hook.Flush())
+	assert.Equal(t, []string{
+		"log:level=warning msg=warn\n",
+		"log:level=error msg=error\n",
+		"log:level=error msg=flush\n",
+	}, sender.values)
+
+	//发送失败写入本地文件
+	fallback := filepath.Join(t.TempDir(), "app.log.kafka")
+	sender = &testSender{fail: true, sending: make(chan struct{}, 1), release: make(chan struct{})}
+	hook, err = NewKafkaHook(KafkaSetting{Topic: "log", FallbackFile: fallback, BatchSize: 1, QueueSize: 1, FlushInterval: time.Hour},
+		sender, &logrus.TextFormatter{DisableTimestamp: true})
+	assert.Nil(t, err)
+	logger = logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	logger.Info("a")
+	<-sender.sending
+	//发送阻塞时不阻塞调用方, 队列满时丢弃
+	logger.Info("b")
+	logger.Info("c")
+	assert.Equal(t, uint64(1), hook.Dropped())
+	close(sender.release)
+	assert.Nil(t, hook.Close())
+	data, err := ioutil.ReadFile(fallback)
+	assert.Nil(t, err)
+	assert.Equal(t, "level=info msg=a\nlevel=info msg=b\n", string(data), "need skip sending after failure")
+
+	//未知级别
+	_, err = NewKafkaHook(KafkaSetting{Topic: "log", Levels: []string{"unknown"}}, &testSender{}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestSyslogHook_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer pc.Close()
+
+	logger, err := NewLog(Setting{Format: "json", Syslog: SyslogSetting{
+		Enable: true, Network: "udp", Address: pc.LocalAddr().String(), Facility: "local0", AppName: "app", Hostname: "host",
+	}})
+	assert.Nil(t, err)
+	_, ok := logger.Writer.(*SyslogHook)
+	assert.True(t, ok, "syslog only need write to syslog")
+	logger.Logger.Warn("warn")
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.Nil(t, err)
+	//local0 warning: 16*8+4
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<132>1 "), msg)
+	assert.Contains(t, msg, " host app "+strconv.Itoa(os.Getpid())+" - - {")
+	assert.Contains(t, msg, `"msg":"warn"`)
+	assert.False(t, strings.HasSuffix(msg, "\n"))
+}
+
+func TestSyslogHook_Level(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer pc.Close()
+
+	//只输出到 syslog 时按配置的级别过滤
+	logger, err := NewLog(Setting{Level: "error", Format: "json", Syslog: SyslogSetting{
+		Enable: true, Network: "udp", Address: pc.LocalAddr().String(), AppName: "app", Hostname: "host",
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "error", logger.GetLevel())
+	logger.Logger.Info("info")
+	logger.Logger.Error("error")
+
+	buf := make([]byte, 1024)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	assert.Nil(t, err)
+	assert.Contains(t, string(buf[:n]), `"msg":"error"`)
+}
+
+func TestSyslogHook_TCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	conns := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	read := func(conn net.Conn) string {
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _ := conn.Read(buf)
+		return string(buf[:n])
+	}
+
+	formatter := &logrus.TextFormatter{DisableTimestamp: true}
+	hook, err := NewSyslogHook(SyslogSetting{Network: "tcp", Address: l.Addr().String(), Protocol: SyslogRFC3164, AppName: "app", Hostname: "host"}, formatter)
+	assert.Nil(t, err)
+	defer hook.Close()
+	_, err = hook.Write([]byte("access\n"))
+	assert.Nil(t, err)
+	conn := <-conns
+	//user info: 1*8+6, 换行分帧
+	msg := read(conn)
+	assert.True(t, strings.HasPrefix(msg, "<14>"), msg)
+	assert.True(t, strings.HasSuffix(msg, " host app["+strconv.Itoa(os.Getpid())+"]: access\n"), msg)
+
+	//连接断开后重新连接
+	_ = conn.Close()
+	hook.mutex.Lock()
+	_ = hook.conn.Close()
+	hook.mutex.Unlock()
+	_, err = hook.Write([]byte("again"))
+	assert.Nil(t, err)
+	assert.Contains(t, read(<-conns), "app["+strconv.Itoa(os.Getpid())+"]: again")
+
+	//RFC 5424 长度前缀分帧
+	hook, err = NewSyslogHook(SyslogSetting{Network: "tcp", Address: l.Addr().String(), AppName: "app", Hostname: "host"}, formatter)
+	assert.Nil(t, err)
+	defer hook.Close()
+	_, err = hook.Write([]byte("framed"))
+	assert.Nil(t, err)
+	msg = read(<-conns)
+	parts := strings.SplitN(msg, " ", 2)
+	assert.Equal(t, strconv.Itoa(len(parts[1])), parts[0])
+	assert.True(t, strings.HasSuffix(msg, " - - framed"), msg)
+
+	_, err = NewSyslogHook(SyslogSetting{Facility: "unknown"}, formatter)
+	assert.NotNil(t, err)
+}
+
+func TestSyslogHook_Blackhole(t *testing.T) {
+	//接受连接但不读取
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	conns := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conns <- conn
+		}
+	}()
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	hook, err := NewSyslogHook(SyslogSetting{Network: "tcp", Address: l.Addr().String(), QueueSize: 4}, &logrus.TextFormatter{})
+	assert.Nil(t, err)
+	logger.AddHook(hook)
+
+	//发送阻塞时日志调用不阻塞, 队列满时丢弃
+	msg := strings.Repeat("x", 64*1024)
+	start := time.Now()
+	for i := 0; i < 300; i++ {
+		logger.Info(msg)
+	}
+	assert.True(t, time.Since(start) < syslogTimeout, "log call need not block on syslog")
+	assert.True(t, hook.Dropped() > 0)
+
+	//断开后关闭不再等待发送超时
+	_ = l.Close()
+	_ = (<-conns).Close()
+	start = time.Now()
+	assert.Nil(t, hook.Close())
+	assert.True(t, time.Since(start) < syslogTimeout+time.Second)
+	_, err = hook.Write([]byte("closed"))
+	assert.NotNil(t, err)
+}
+
+func TestRedactHook(t *testing.T) {
+	hook, err := NewRedactHook(RedactSetting{Enable: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "call 138****8000, 8613800138000123 not mobile", hook.RedactString("call 13800138000, 8613800138000123 not mobile"))
+	assert.Equal(t, "mail zha*************.com", hook.RedactString("mail zhangsan@example.com"))
+	assert.Equal(t, "Authorization: Bearer eyJ********z789", hook.RedactString("Authorization: Bearer eyJhbGciOiJz789"))
+
+	logger := logrus.New()
+	buf := &strings.Builder{}
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(hook)
+	entry := logger.WithFields(logrus.Fields{"access_token": "abcdefgh12345678", "Password": "123", "phone": "13800138000", "uid": 1})
+	entry.Info("login 13800138000")
+	assert.Contains(t, buf.String(), `"access_token":"abc*********5678"`)
+	assert.Contains(t, buf.String(), `"Password":"******"`)
+	assert.Contains(t, buf.String(), `"phone":"138****8000"`)
+	assert.Contains(t, buf.String(), `"msg":"login 138****8000"`)
+	assert.Contains(t, buf.String(), `"uid":1`)
+	assert.Equal(t, "13800138000", entry.Data["phone"], "need not modify caller fields")
+
+	hook, err = NewRedactHook(RedactSetting{Enable: true, Keys: []string{"card"}, Patterns: []string{`id=(?P<secret>\d+)`}, Mode: MaskHash})
+	assert.Nil(t, err)
+	assert.Equal(t, "id=sha256:8d969eef, 13800138000", hook.RedactString("id=123456, 13800138000"))
+	assert.Equal(t, "sha256:8d969eef", hook.redactValue("Card", 123456))
+
+	_, err = NewRedactHook(RedactSetting{Patterns: []string{"("}})
+	assert.NotNil(t, err)
+	_, err = NewRedactHook(RedactSetting{Mode: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestLog_Redact(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewLog(Setting{Path: dir, FileName: "a.log", Level: "info", Split: ".%Y%m%d", Format: "ydLog",
+		Redact: RedactSetting{Enable: true, Mode: MaskFull}})
+	assert.Nil(t, err)
+	logger.Logger.WithField("token", "abc").Info("mobile 13800138000")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `token="******"`)
+	assert.Contains(t, string(data), `msg="mobile ******"`)
+	assert.NotContains(t, string(data), "13800138000")
+}
@@ -0,0 +1,252 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jeevi-cao/lego/components/validation"
+)
+
+//敏感信息脱敏
+//usage:
+//
+//	c := Setting{
+//		...
+//		Redact: RedactSetting{
+//			Enable:   true,
+//			Keys:     []string{"password", "*token*"},
+//			Patterns: []string{"mobile", "email", "bearer", `id_card=(?P<secret>\d{17}[\dxX])`},
+//			Mode:     MaskPartial,
+//		},
+//	}
+//
+//	作为第一个 hook 执行, 文件 kafka syslog 等 hook 及格式化看到的都是脱敏后的内容
+//	Keys      字段名匹配(不区分大小写, 支持 * 通配), 匹配的字段整个值脱敏
+//	Patterns  值正则, 对消息及字符串字段中匹配的部分脱敏, 包含命名分组 secret 时只脱敏该分组
+//	          内置名称 mobile email bearer, 手机号及邮箱使用 validation 的规则
+//	Keys Patterns 为空时使用默认值
+
+//脱敏方式
+const (
+	//全部替换为 ******
+	MaskFull = "full"
+	//保留前3位及后4位, 长度不足时全部替换, 如 138****8000
+	MaskPartial = "partial"
+	//替换为 sha256 前8位, 相同的值可关联
+	MaskHash = "hash"
+)
+
+const (
+	maskText = "******"
+	//只脱敏的命名分组
+	secretGroup = "secret"
+)
+
+//默认脱敏字段名
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "*token*", "authorization", "cookie"}
+
+//默认脱敏规则
+var DefaultRedactPatterns = []string{"mobile", "email", "bearer"}
+
+//内置规则
+var redactPatterns = map[string]string{
+	"mobile": validation.MobileExpr,
+	"email":  validation.EmailExpr,
+	"bearer": `(?i)bearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`,
+}
+
+//脱敏配置
+type RedactSetting struct {
+	Enable   bool
+	Keys     []string //字段名匹配
+	Patterns []string //值正则或内置名称
+	Mode     string   //full partial hash, 默认 partial
+}
+
+type redactRule struct {
+	re *regexp.Regexp
+	//secret 分组下标, 没有时为 0 整个匹配
+	group int
+	//匹配前后不能是数字, 避免误匹配更长的数字
+	digitBoundary bool
+}
+
+//脱敏 hook, 需要在其它 hook 之前添加
+type RedactHook struct {
+	keys  []string
+	rules []*redactRule
+	mode  string
+}
+
+//实例化, 规则不合法时返回错误
+func NewRedactHook(setting RedactSetting) (*RedactHook, error) {
+	mode := setting.Mode
+	if len(mode) == 0 {
+		mode = MaskPartial
+	}
+	if mode != MaskFull && mode != MaskPartial && mode != MaskHash {
+		return nil, errors.New(fmt.Sprintf("unknown redact mode:%s", mode))
+	}
+	keys := setting.Keys
+	if len(keys) == 0 {
+		keys = DefaultRedactKeys
+	}
+	patterns := setting.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultRedactPatterns
+	}
+	h := &RedactHook{mode: mode}
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, errors.New(fmt.Sprintf("redact key:%s error:%s", key, err.Error()))
+		}
+		h.keys = append(h.keys, key)
+	}
+	for _, pattern := range patterns {
+		rule, err := newRedactRule(pattern)
+		if err != nil {
+			return nil, err
+		}
+		h.rules = append(h.rules, rule)
+	}
+	return h, nil
+}
+
+func newRedactRule(pattern string) (*redactRule, error) {
+	expr, builtin := redactPatterns[pattern]
+	if !builtin {
+		expr = pattern
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("redact pattern:%s error:%s", pattern, err.Error()))
+	}
+	rule := &redactRule{re: re, digitBoundary: pattern == "mobile"}
+	for i, name := range re.SubexpNames() {
+		if name == secretGroup {
+			rule.group = i
+		}
+	}
+	return rule, nil
+}
+
+//按配置创建, 未开启时返回 nil
+func newRedactHookFromSetting(c *Setting) (*RedactHook, error) {
+	if c == nil || !c.Redact.Enable {
+		return nil, nil
+	}
+	return NewRedactHook(c.Redact)
+}
+
+func (h *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+//替换为脱敏后的字段及消息, entry.Data 可能被调用方共享, 不修改原字段
+func (h *RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.RedactString(entry.Message)
+	if len(entry.Data) == 0 {
+		return nil
+	}
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = h.redactValue(k, v)
+	}
+	entry.Data = data
+	return nil
+}
+
+//字段名匹配时整个值脱敏, 否则对字符串值按规则脱敏
+func (h *RedactHook) redactValue(key string, v interface{}) interface{} {
+	if h.matchKey(key) {
+		return h.mask(fmt.Sprint(v))
+	}
+	switch value := v.(type) {
+	case string:
+		return h.RedactString(value)
+	case []byte:
+		return h.RedactString(string(value))
+	case error:
+		//未匹配时保留原值
+		if s := h.RedactString(value.Error()); s != value.Error() {
+			return s
+		}
+	case fmt.Stringer:
+		if s := h.RedactString(value.String()); s != value.String() {
+			return s
+		}
+	}
+	return v
+}
+
+func (h *RedactHook) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range h.keys {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//按规则脱敏字符串中匹配的部分
+func (h *RedactHook) RedactString(s string) string {
+	for _, rule := range h.rules {
+		s = h.redactRule(rule, s)
+	}
+	return s
+}
+
+func (h *RedactHook) redactRule(rule *redactRule, s string) string {
+	matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*rule.group], m[2*rule.group+1]
+		if start < 0 {
+			continue
+		}
+		if rule.digitBoundary && (isDigitAt(s, start-1) || isDigitAt(s, end)) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(h.mask(s[start:end]))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isDigitAt(s string, i int) bool {
+	return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+//按脱敏方式处理
+func (h *RedactHook) mask(s string) string {
+	switch h.mode {
+	case MaskFull:
+		return maskText
+	case MaskHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:4])
+	default:
+		n := utf8.RuneCountInString(s)
+		if n < 8 {
+			return maskText
+		}
+		runes := []rune(s)
+		return string(runes[:3]) + strings.Repeat("*", n-7) + string(runes[n-4:])
+	}
+}
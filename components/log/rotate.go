@@ -0,0 +1,234 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+)
+
+//日志文件保留策略
+//	文件按时间或大小切割后在后台处理已切割的文件:
+//	Compress      gzip 压缩, 压缩后保留原修改时间, LifeTime 过期清理仍然有效
+//	MaxBackups    保留的已切割文件数量, 超出时删除最早的
+//	MaxTotalSize  日志文件总大小(含当前文件), 超出时从最早的已切割文件开始删除
+
+const (
+	compressSuffix = ".gz"
+	megabyte       = 1024 * 1024
+)
+
+// 按时间及大小切割的文件writer, 返回需要关闭的对象
+func newFileWriter(basePath string, c *Setting) (io.Writer, []io.Closer, error) {
+	options := []rotatelogs.Option{
+		rotatelogs.WithLinkName(basePath),                   //生成软连接, 指向最新日志文件
+		rotatelogs.WithMaxAge(c.LifeTime * time.Hour),       //文件最大保存时间 单位:时间
+		rotatelogs.WithRotationTime(c.Rotation * time.Hour), //文件切割时间时间
+	}
+	if c.RotationSize > 0 {
+		options = append(options, rotatelogs.WithRotationSize(c.RotationSize*megabyte))
+	}
+	var r *retention
+	if c.Compress || c.MaxBackups > 0 || c.MaxTotalSize > 0 {
+		r = newRetention(basePath+c.Split, c.Compress, c.MaxBackups, c.MaxTotalSize*megabyte)
+		options = append(options, rotatelogs.WithHandler(r))
+	}
+	rl, err := rotatelogs.New(basePath+c.Split, options...)
+	if err != nil {
+		if r != nil {
+			_ = r.Close()
+		}
+		return nil, nil, err
+	}
+	var w io.Writer = rl
+	var closers []io.Closer
+	if c.Async {
+		aw := newAsyncWriter(rl, c.AsyncQueueSize, c.AsyncPolicy, c.AsyncFlushInterval)
+		w = aw
+		closers = append(closers, aw)
+	} else {
+		closers = append(closers, rl)
+	}
+	if r != nil {
+		r.current = rl.CurrentFileName
+		closers = append(closers, r)
+	}
+	return w, closers, nil
+}
+
+// 已切割文件的后台处理
+type retention struct {
+	glob       string
+	compress   bool
+	maxBackups int
+	//总大小 单位:字节
+	maxTotalSize int64
+	current      func() string
+	notify       chan struct{}
+	done         chan struct{}
+	finished     chan struct{}
+	once         sync.Once
+}
+
+func newRetention(pattern string, compress bool, maxBackups int, maxTotalSize int64) *retention {
+	glob := pattern
+	if i := strings.Index(glob, "%"); i >= 0 {
+		glob = glob[:i] + "*"
+	}
+	r := &retention{
+		glob:         glob,
+		compress:     compress,
+		maxBackups:   maxBackups,
+		maxTotalSize: maxTotalSize,
+		current:      func() string { return "" },
+		notify:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		finished:     make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// 文件切割时触发
+func (r *retention) Handle(e rotatelogs.Event) {
+	if _, ok := e.(*rotatelogs.FileRotatedEvent); !ok {
+		return
+	}
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (r *retention) loop() {
+	defer close(r.finished)
+	for {
+		select {
+		case <-r.notify:
+			r.cleanup()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// 等待进行中的处理完成后退出
+func (r *retention) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	<-r.finished
+	return nil
+}
+
+type backup struct {
+	path string
+	info os.FileInfo
+}
+
+// 压缩已切割文件, 按数量及总大小删除最早的文件
+func (r *retention) cleanup() {
+	current := r.current()
+	backups, total := r.backups(current)
+	if r.compress {
+		for i, b := range backups {
+			if strings.HasSuffix(b.path, compressSuffix) {
+				continue
+			}
+			gz, err := compressFile(b.path)
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "log compress %s error:%s\n", b.path, err.Error())
+				continue
+			}
+			if info, err := os.Stat(gz); err == nil {
+				total += info.Size() - b.info.Size()
+				backups[i] = backup{path: gz, info: info}
+			}
+		}
+	}
+	//最新的在前
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].info.ModTime().After(backups[j].info.ModTime())
+	})
+	for i := len(backups) - 1; i >= 0; i-- {
+		overCount := r.maxBackups > 0 && i >= r.maxBackups
+		overSize := r.maxTotalSize > 0 && total > r.maxTotalSize
+		if !overCount && !overSize {
+			break
+		}
+		if err := os.Remove(backups[i].path); err == nil {
+			total -= backups[i].info.Size()
+		}
+	}
+}
+
+// 已切割的文件及包含当前文件的总大小
+func (r *retention) backups(current string) ([]backup, int64) {
+	matches, _ := filepath.Glob(r.glob)
+	backups := make([]backup, 0, len(matches))
+	var total int64
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") || strings.HasSuffix(path, ".tmp") {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		total += info.Size()
+		if path == current {
+			continue
+		}
+		backups = append(backups, backup{path: path, info: info})
+	}
+	return backups, total
+}
+
+// gzip 压缩文件, 成功后删除原文件, 返回压缩文件路径
+func compressFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	target := path + compressSuffix
+	for i := 1; ; i++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			break
+		}
+		target = fmt.Sprintf("%s.%d%s", path, i, compressSuffix)
+	}
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	_ = os.Chtimes(target, info.ModTime(), info.ModTime())
+	return target, os.Remove(path)
+}
@@ -0,0 +1,396 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+//syslog 日志
+//usage:
+//
+//	c := Setting{
+//		Format: "json",
+//		Syslog: SyslogSetting{
+//			Enable:   true,
+//			Network:  "udp",
+//			Address:  "127.0.0.1:514",
+//			Protocol: SyslogRFC5424,
+//			Facility: "local0",
+//			AppName:  "app",
+//		},
+//	}
+//	logger, err := NewLog(c)
+//
+//	未配置 Path 时只输出到 syslog, 配置 Path 时同时写入文件及 syslog
+//	消息内容使用日志格式化, tcp 及 unix 流式连接按 RFC 6587 分帧: RFC 5424 使用长度前缀, RFC 3164 使用换行
+//	日志格式化后写入内存队列, 调用方不阻塞, 队列满时丢弃并计数, 后台协程连接并发送
+//	写入失败时关闭连接并重新连接重试一次, 仍然失败时丢弃, 连接失败后 syslogRetryInterval 内不再连接
+
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+
+	//连接及写入超时
+	syslogTimeout = 5 * time.Second
+	//连接失败后暂停连接的时间
+	syslogRetryInterval = time.Second
+	//默认队列长度 单位:条
+	DefaultSyslogQueueSize = 1024
+)
+
+//facility 名称
+var SyslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+//日志级别对应的 severity
+var syslogSeverities = map[logrus.Level]int{
+	logrus.PanicLevel: 2,
+	logrus.FatalLevel: 2,
+	logrus.ErrorLevel: 3,
+	logrus.WarnLevel:  4,
+	logrus.InfoLevel:  6,
+	logrus.DebugLevel: 7,
+	logrus.TraceLevel: 7,
+}
+
+//本地 syslog 默认地址
+var syslogLocalAddresses = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+//syslog 配置
+type SyslogSetting struct {
+	Enable    bool
+	Network   string //udp tcp unix unixgram, 默认 udp, 未配置 Address 时连接本地 unix socket
+	Address   string //host:port 或 unix socket 路径
+	Protocol  string //rfc5424 或 rfc3164, 默认 rfc5424
+	Facility  string //默认 user
+	AppName   string //默认进程名
+	Hostname  string //默认本机名称
+	Format    string //消息内容格式 json text ydLog, 默认与文件日志相同
+	QueueSize int    //队列长度 单位:条
+}
+
+//发送日志到 syslog 的 hook, 同时可作为 Writer 以 info 级别写入
+type SyslogHook struct {
+	setting   SyslogSetting
+	formatter logrus.Formatter
+	facility  int
+	pid       int
+	queue     chan *syslogMessage
+	dropped   uint64
+	flush     chan chan error
+	done      chan struct{}
+	finished  chan struct{}
+	closed    bool
+	//写入队列与关闭互斥
+	state sync.RWMutex
+
+	conn net.Conn
+	//流式连接 需要分帧
+	stream   bool
+	failedAt time.Time
+	//连接互斥
+	mutex sync.Mutex
+}
+
+//待发送的消息
+type syslogMessage struct {
+	severity int
+	time     time.Time
+	body     []byte
+}
+
+//实例化, 后台协程首次发送时连接
+func NewSyslogHook(setting SyslogSetting, formatter logrus.Formatter) (*SyslogHook, error) {
+	if len(setting.Protocol) == 0 {
+		setting.Protocol = SyslogRFC5424
+	}
+	if setting.Protocol != SyslogRFC5424 && setting.Protocol != SyslogRFC3164 {
+		return nil, errors.New(fmt.Sprintf("unknown syslog protocol:%s", setting.Protocol))
+	}
+	if len(setting.Facility) == 0 {
+		setting.Facility = "user"
+	}
+	facility, ok := SyslogFacilities[setting.Facility]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown syslog facility:%s", setting.Facility))
+	}
+	if len(setting.AppName) == 0 {
+		setting.AppName = filepath.Base(os.Args[0])
+	}
+	if len(setting.Hostname) == 0 {
+		setting.Hostname, _ = os.Hostname()
+	}
+	if setting.QueueSize <= 0 {
+		setting.QueueSize = DefaultSyslogQueueSize
+	}
+	if formatter == nil {
+		formatter = &logrus.JSONFormatter{}
+	}
+	h := &SyslogHook{
+		setting:   setting,
+		formatter: formatter,
+		facility:  facility,
+		pid:       os.Getpid(),
+		queue:     make(chan *syslogMessage, setting.QueueSize),
+		flush:     make(chan chan error),
+		done:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+	go h.loop()
+	return h, nil
+}
+
+//按日志配置创建, 未开启时返回 nil
+func newSyslogHookFromSetting(c *Setting) (*SyslogHook, error) {
+	if c == nil || !c.Syslog.Enable {
+		return nil, nil
+	}
+	format := c.Syslog.Format
+	if len(format) == 0 {
+		format = c.Format
+	}
+	return NewSyslogHook(c.Syslog, newFormatter(format, c))
+}
+
+func (h *SyslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+//格式化后写入队列, 队列满时丢弃
+func (h *SyslogHook) Fire(entry *logrus.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	h.enqueue(syslogSeverities[entry.Level], entry.Time, b)
+	return nil
+}
+
+//以 info 级别写入, 用于 http 访问日志等直接写入 Writer 的使用方
+func (h *SyslogHook) Write(p []byte) (int, error) {
+	if !h.enqueue(syslogSeverities[logrus.InfoLevel], time.Now(), p) {
+		return 0, errors.New("syslog hook closed")
+	}
+	return len(p), nil
+}
+
+//写入队列, 调用方可能复用 b, 需要复制, 已关闭时返回 false
+func (h *SyslogHook) enqueue(severity int, t time.Time, b []byte) bool {
+	defer h.state.RUnlock()
+	h.state.RLock()
+	if h.closed {
+		return false
+	}
+	select {
+	case h.queue <- &syslogMessage{severity: severity, time: t, body: append([]byte{}, b...)}:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+	return true
+}
+
+func (h *SyslogHook) loop() {
+	defer close(h.finished)
+	//发送队列中已有的日志
+	drain := func() error {
+		var first error
+		for {
+			select {
+			case m := <-h.queue:
+				if err := h.send(m); err != nil && first == nil {
+					first = err
+				}
+			default:
+				return first
+			}
+		}
+	}
+	for {
+		select {
+		case m := <-h.queue:
+			_ = h.send(m)
+		case ch := <-h.flush:
+			ch <- drain()
+		case <-h.done:
+			_ = drain()
+			return
+		}
+	}
+}
+
+//发送失败时丢弃并计数
+func (h *SyslogHook) send(m *syslogMessage) error {
+	err := h.write(m.severity, m.time, m.body)
+	if err != nil {
+		atomic.AddUint64(&h.dropped, 1)
+	}
+	return err
+}
+
+//生成消息
+func (h *SyslogHook) message(severity int, t time.Time, body []byte) []byte {
+	body = bytes.TrimRight(body, "\n")
+	pri := h.facility*8 + severity
+	var b bytes.Buffer
+	if h.setting.Protocol == SyslogRFC3164 {
+		//<PRI>TIMESTAMP HOSTNAME TAG[PID]: MSG
+		_, _ = fmt.Fprintf(&b, "<%d>%s %s %s[%d]: ", pri, t.Format(time.Stamp), h.setting.Hostname, h.setting.AppName, h.pid)
+	} else {
+		//<PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+		_, _ = fmt.Fprintf(&b, "<%d>1 %s %s %s %d - - ", pri, t.Format("2006-01-02T15:04:05.000000Z07:00"), nilValue(h.setting.Hostname), nilValue(h.setting.AppName), h.pid)
+	}
+	b.Write(body)
+	if !h.stream {
+		return b.Bytes()
+	}
+	if h.setting.Protocol == SyslogRFC3164 {
+		b.WriteByte('\n')
+		return b.Bytes()
+	}
+	//octet counting
+	return append([]byte(strconv.Itoa(b.Len())+" "), b.Bytes()...)
+}
+
+func nilValue(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return s
+}
+
+//写入失败时重新连接重试一次
+func (h *SyslogHook) write(severity int, t time.Time, body []byte) error {
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	var err error
+	for i := 0; i < 2; i++ {
+		if h.conn == nil {
+			if err = h.connect(); err != nil {
+				return err
+			}
+		}
+		_ = h.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = h.conn.Write(h.message(severity, t, body)); err == nil {
+			return nil
+		}
+		_ = h.conn.Close()
+		h.conn = nil
+	}
+	return err
+}
+
+func (h *SyslogHook) connect() error {
+	if time.Since(h.failedAt) < syslogRetryInterval {
+		return errors.New("syslog connect failed recently, skip")
+	}
+	conn, stream, err := dialSyslog(h.setting.Network, h.setting.Address)
+	if err != nil {
+		h.failedAt = time.Now()
+		log.Printf("[log] syslog connect error:%s, drop log until reconnect", err)
+		return errors.New(fmt.Sprintf("syslog connect error:%s", err.Error()))
+	}
+	h.conn = conn
+	h.stream = stream
+	return nil
+}
+
+//连接 syslog, 返回是否为流式连接
+func dialSyslog(network, address string) (net.Conn, bool, error) {
+	if len(address) == 0 {
+		//本地 unix socket
+		for _, addr := range syslogLocalAddresses {
+			for _, n := range []string{"unixgram", "unix"} {
+				if conn, err := net.DialTimeout(n, addr, syslogTimeout); err == nil {
+					return conn, n == "unix", nil
+				}
+			}
+		}
+		return nil, false, errors.New("local syslog not found")
+	}
+	switch network {
+	case "", "udp":
+		conn, err := net.DialTimeout("udp", address, syslogTimeout)
+		return conn, false, err
+	case "unix":
+		//优先数据报
+		if conn, err := net.DialTimeout("unixgram", address, syslogTimeout); err == nil {
+			return conn, false, nil
+		}
+		conn, err := net.DialTimeout("unix", address, syslogTimeout)
+		return conn, true, err
+	default:
+		conn, err := net.DialTimeout(network, address, syslogTimeout)
+		return conn, strings.HasPrefix(network, "tcp"), err
+	}
+}
+
+//等待队列中的日志发送完成, 返回发送失败的错误
+func (h *SyslogHook) Flush() error {
+	defer h.state.RUnlock()
+	h.state.RLock()
+	if h.closed {
+		return nil
+	}
+	ch := make(chan error, 1)
+	h.flush <- ch
+	return <-ch
+}
+
+//丢弃的日志条数, 包含队列满及发送失败
+func (h *SyslogHook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+//发送队列中的日志后关闭连接
+func (h *SyslogHook) Close() error {
+	h.state.Lock()
+	if h.closed {
+		h.state.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.state.Unlock()
+	close(h.done)
+	<-h.finished
+
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
@@ -100,6 +100,11 @@ func (m *Mongo) GetClient() *mongo.Client {
 	return m.Client
 }
 
+//检查连接是否可用
+func (m *Mongo) Ping(ctx context.Context) error {
+	return m.Client.Ping(ctx, nil)
+}
+
 func (m *Mongo) Close() {
 	ctx := context.Background()
 	_ = m.Client.Disconnect(ctx)
@@ -0,0 +1,200 @@
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//pid文件
+//usage:
+//
+//	p := pidfile.New("./indexer.pid")
+//	if err := p.Lock(); err != nil {
+//		//已有实例运行
+//	}
+//	defer p.Remove()
+//
+//	使用 <pidfile>.lock 文件 flock 排他锁, 进程退出时系统自动释放
+//	pid文件先写入临时文件再重命名, 不会出现残留字符
+//	pid文件存在但进程已不存在时视为过期并替换
+
+type PidFile struct {
+	Path string
+	//持有锁的文件
+	lock *os.File
+	pid  int
+	//替换的过期pid
+	stale int
+}
+
+//实例化
+func New(path string) *PidFile {
+	return &PidFile{Path: path}
+}
+
+//锁文件地址
+func (p *PidFile) LockPath() string {
+	return p.Path + ".lock"
+}
+
+//加锁并写入当前进程pid, 已有实例运行时返回错误
+func (p *PidFile) Lock() error {
+	if p.lock != nil {
+		return errors.New(fmt.Sprintf("pid file:%s already locked", p.Path))
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(p.LockPath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.New(fmt.Sprintf("open pid lock file:%s error:%s", p.LockPath(), err.Error()))
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = lock.Close()
+		if err == syscall.EWOULDBLOCK {
+			pid, _ := Read(p.Path)
+			return errors.New(fmt.Sprintf("pid file:%s locked, already running pid:%d", p.Path, pid))
+		}
+		return errors.New(fmt.Sprintf("lock pid file:%s error:%s", p.Path, err.Error()))
+	}
+
+	//未加锁的旧版本实例仍在运行
+	pid := os.Getpid()
+	p.stale = 0
+	if old, err := Read(p.Path); err == nil && old != pid {
+		if IsRunning(old) {
+			_ = lock.Close()
+			return errors.New(fmt.Sprintf("pid file:%s process pid:%d still running", p.Path, old))
+		}
+		p.stale = old
+	}
+	if err := write(p.Path, pid); err != nil {
+		_ = lock.Close()
+		return errors.New(fmt.Sprintf("write pid file:%s error:%s", p.Path, err.Error()))
+	}
+	p.lock = lock
+	p.pid = pid
+	return nil
+}
+
+//继承父进程持有锁的文件并写入当前进程pid, 平滑重启时使用
+//父进程仍在运行, 记录为替换的pid
+func (p *PidFile) Inherit(lock *os.File) error {
+	if p.lock != nil {
+		return errors.New(fmt.Sprintf("pid file:%s already locked", p.Path))
+	}
+	pid := os.Getpid()
+	p.stale = 0
+	if old, err := Read(p.Path); err == nil && old != pid {
+		p.stale = old
+	}
+	if err := write(p.Path, pid); err != nil {
+		return errors.New(fmt.Sprintf("write pid file:%s error:%s", p.Path, err.Error()))
+	}
+	p.lock = lock
+	p.pid = pid
+	return nil
+}
+
+//持有锁的文件, 平滑重启时传给新进程
+func (p *PidFile) LockFile() *os.File {
+	return p.lock
+}
+
+//加锁时替换的过期pid, 没有返回0
+func (p *PidFile) StalePid() int {
+	return p.stale
+}
+
+//当前进程pid
+func (p *PidFile) Pid() int {
+	return p.pid
+}
+
+//删除pid文件并释放锁, 文件中的pid不是当前进程时不删除
+//已交给新进程时只关闭句柄, 锁由新进程继续持有
+func (p *PidFile) Remove() error {
+	if p.lock == nil {
+		return nil
+	}
+	var err error
+	if pid, e := Read(p.Path); e == nil && pid == p.pid {
+		err = os.Remove(p.Path)
+		//锁文件保留, 删除后其他进程可能锁住不同的文件
+		_ = syscall.Flock(int(p.lock.Fd()), syscall.LOCK_UN)
+	}
+	_ = p.lock.Close()
+	p.lock = nil
+	return err
+}
+
+//原子写入
+func write(path string, pid int) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(strconv.Itoa(pid) + "\n"); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	_ = tmp.Close()
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+//读取pid文件
+func Read(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, errors.New(fmt.Sprintf("pid file:%s invalid content", path))
+	}
+	return pid, nil
+}
+
+//进程是否存在
+func IsRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+//向pid文件中的进程发送信号, 进程不存在时返回错误
+func Signal(path string, sig syscall.Signal) (int, error) {
+	pid, err := Read(path)
+	if err != nil {
+		return 0, err
+	}
+	if !IsRunning(pid) {
+		return pid, errors.New(fmt.Sprintf("process pid:%d not running, stale pid file:%s", pid, path))
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return pid, errors.New(fmt.Sprintf("signal pid:%d error:%s", pid, err.Error()))
+	}
+	return pid, nil
+}
@@ -0,0 +1,106 @@
+package pidfile
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPidFile_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "app.pid")
+	p := New(path)
+	assert.Nil(t, p.Lock())
+	pid, err := Read(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+
+	//重复启动
+	assert.NotNil(t, New(path).Lock())
+
+	assert.Nil(t, p.Remove())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	//释放后可再次加锁
+	p2 := New(path)
+	assert.Nil(t, p2.Lock())
+	assert.Nil(t, p2.Remove())
+}
+
+func TestPidFile_Stale(t *testing.T) {
+	//已退出进程的pid
+	cmd := exec.Command("true")
+	assert.Nil(t, cmd.Run())
+	stale := cmd.Process.Pid
+	assert.False(t, IsRunning(stale))
+
+	path := filepath.Join(t.TempDir(), "app.pid")
+	//较长的旧内容 不残留
+	assert.Nil(t, ioutil.WriteFile(path, []byte(strconv.Itoa(stale)+"999999\n"), 0644))
+	p := New(path)
+	assert.Nil(t, p.Lock())
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+	assert.Nil(t, p.Remove())
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(strconv.Itoa(stale)), 0644))
+	p = New(path)
+	assert.Nil(t, p.Lock())
+	assert.Equal(t, stale, p.StalePid())
+	assert.Nil(t, p.Remove())
+
+	//未加锁的运行中进程
+	cmd = exec.Command("sleep", "5")
+	assert.Nil(t, cmd.Start())
+	defer cmd.Process.Kill()
+	assert.Nil(t, ioutil.WriteFile(path, []byte(strconv.Itoa(cmd.Process.Pid)), 0644))
+	assert.NotNil(t, New(path).Lock())
+}
+
+func TestSignal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	p := New(path)
+	assert.Nil(t, p.Lock())
+	defer p.Remove()
+
+	pid, err := Signal(path, syscall.Signal(0))
+	assert.Nil(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+
+	_, err = Signal(filepath.Join(t.TempDir(), "none.pid"), syscall.SIGTERM)
+	assert.NotNil(t, err)
+}
+
+func TestPidFile_Inherit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	p := New(path)
+	assert.Nil(t, p.Lock())
+	//新进程继承的句柄
+	fd, err := syscall.Dup(int(p.LockFile().Fd()))
+	assert.Nil(t, err)
+	lock := os.NewFile(uintptr(fd), p.LockPath())
+
+	//新进程已写入pid, 旧进程关闭时不删除也不释放锁
+	assert.Nil(t, ioutil.WriteFile(path, []byte("1\n"), 0644))
+	assert.Nil(t, p.Remove())
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+	assert.NotNil(t, New(path).Lock())
+
+	p2 := New(path)
+	assert.Nil(t, p2.Inherit(lock))
+	assert.Equal(t, 1, p2.StalePid())
+	pid, _ := Read(path)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.Nil(t, p2.Remove())
+
+	p3 := New(path)
+	assert.Nil(t, p3.Lock())
+	assert.Nil(t, p3.Remove())
+}
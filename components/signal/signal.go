@@ -1,123 +1,281 @@
 package sig
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
 //signal 信号量处理工具
 //usage:
-// WatchSingal函数注册shutdown restart reconfig 三个函数即可
+//
+//	s := sig.New()
+//	unregister := s.Handle(syscall.SIGUSR2, func(ctx context.Context, sig os.Signal) error {
+//		return reload(ctx)
+//	})
+//	s.Start()
+//	defer s.Stop()
+//	...
+//	unregister()
+//
+//	每个信号按注册顺序串行执行处理函数, 不同信号并行处理
+//	处理函数在 timeout 内未返回时不再等待, panic 转换为错误
+//	关闭信号处理中再次收到 SIGINT SIGTERM 时立即退出进程
+//	测试时使用 Inject 模拟收到信号, Dispatch 同步执行处理函数
 
-var sig *Signal
-var once sync.Once
+//默认监听的信号
+//SIGINT SIGTERM SIGHUP 终止信号
+//SIGUSR1 SIGUSR2 用户定义 SIGUSR1:平滑重启  SIGUSR2:配置重新加载
+var DefaultSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2}
 
-func init() {
-	once.Do(func() {
-		sig = &Signal{
-			SignalChan: make(chan os.Signal, 1),
-			Callbacks:  make([]CallbackSignal, 0),
-			StopChan:   make(chan struct{}, 1),
-		}
-	})
-}
+const (
+	//单个处理函数默认超时
+	DefaultTimeout = 60 * time.Second
+	//再次收到关闭信号强制退出的退出码
+	ForceExitCode = 1
+)
 
-type CallbackSignal func(sig os.Signal)
+//信号处理函数, ctx 在超时后取消
+type Handler func(ctx context.Context, sig os.Signal) error
+
+type entry struct {
+	id int
+	f  Handler
+}
 
 //信号量结构体
 type Signal struct {
-	SignalChan chan os.Signal
-	StopChan   chan struct{}
-	Callbacks  []CallbackSignal
-	Running    bool
+	signals  []os.Signal
+	handlers map[os.Signal][]*entry
+	nextId   int
+	timeout  time.Duration
+	//收到的信号
+	notify chan os.Signal
+	stop   chan struct{}
+	//已收到关闭信号
+	stopping bool
+	running  bool
+	exit     func(code int)
+	onError  func(sig os.Signal, err error)
+	mutex    sync.Mutex
 }
 
-//addWatchFunc
-func (s *Signal) AddWatchFunc(f CallbackSignal) *Signal {
-	s.Callbacks = append(s.Callbacks, f)
-	return s
+//实例化, 未指定信号时监听 DefaultSignals
+func New(signals ...os.Signal) *Signal {
+	if len(signals) == 0 {
+		signals = DefaultSignals
+	}
+	return &Signal{
+		signals:  append([]os.Signal{}, signals...),
+		handlers: make(map[os.Signal][]*entry),
+		timeout:  DefaultTimeout,
+		notify:   make(chan os.Signal, 4),
+		exit:     os.Exit,
+		onError: func(sig os.Signal, err error) {
+			log.Printf("[signal] %s handler error:%s", sig, err)
+		},
+	}
 }
 
-func (s *Signal) WatchAsync() chan struct{} {
-	//SIGINT SiGTERM  SIGHUP  终止信号
-	//SIGUSR1 SIGUSR2 用户定义 SIGUSR1:定义平滑重启  SIGUSR2 配置重新加载
-	signal.Notify(s.SignalChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
-	s.Running = true
-	go func() {
-		for {
-			sig := <-s.SignalChan
-			var wg sync.WaitGroup
-			for _, f := range s.Callbacks {
-				f := f
-				go func() {
-					wg.Add(1)
-					f(sig)
-					wg.Done()
-				}()
-			}
-			wg.Wait()
+var std = New()
 
-			if sig == syscall.SIGSTOP {
-				s.Running = false
-				//clear all watch func
-				s.Clear()
-				s.StopChan <- struct{}{}
+//默认实例
+func Default() *Signal {
+	return std
+}
+
+//注册信号处理函数, 返回取消注册的函数
+func (s *Signal) Handle(sig os.Signal, f Handler) func() {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.nextId++
+	id := s.nextId
+	s.handlers[sig] = append(s.handlers[sig], &entry{id: id, f: f})
+	return func() {
+		defer s.mutex.Unlock()
+		s.mutex.Lock()
+		list := s.handlers[sig]
+		for i, e := range list {
+			if e.id == id {
+				s.handlers[sig] = append(list[:i:i], list[i+1:]...)
 				break
 			}
 		}
+	}
+}
 
-	}()
+//设置监听的信号, 运行中修改时立即生效
+func (s *Signal) SetSignals(signals ...os.Signal) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.signals = append([]os.Signal{}, signals...)
+	if s.running {
+		signal.Stop(s.notify)
+		signal.Notify(s.notify, s.signals...)
+	}
+}
 
-	return s.StopChan
+//设置单个处理函数超时
+func (s *Signal) SetTimeout(timeout time.Duration) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.timeout = timeout
 }
 
-func (s *Signal) Start() {
-	<-s.WatchAsync()
+//设置强制退出函数, 默认 os.Exit
+func (s *Signal) SetExit(f func(code int)) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.exit = f
+}
+
+//设置处理函数错误回调, 默认输出到标准日志
+func (s *Signal) OnError(f func(sig os.Signal, err error)) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	s.onError = f
 }
 
-func (s *Signal) StartAsync() {
-	s.WatchAsync()
+//开始监听, 重复调用忽略
+func (s *Signal) Start() {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopping = false
+	s.stop = make(chan struct{})
+	signal.Notify(s.notify, s.signals...)
+	go s.loop(s.stop)
 }
 
-//only stop signal self
+//停止监听, 不影响已注册的处理函数
 func (s *Signal) Stop() {
-	if sig.Running {
-		s.SignalChan <- syscall.SIGSTOP
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	if !s.running {
+		return
 	}
+	s.running = false
+	signal.Stop(s.notify)
+	close(s.stop)
 }
 
-func (s *Signal) Clear() {
-	s.Callbacks = make([]CallbackSignal, 0)
+//是否在监听
+func (s *Signal) IsRunning() bool {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+	return s.running
 }
 
-func AddWatchFunc(f CallbackSignal) {
-	sig.AddWatchFunc(f)
+//模拟收到信号, 未开始监听时忽略
+func (s *Signal) Inject(sig os.Signal) {
+	if s.IsRunning() {
+		s.notify <- sig
+	}
 }
 
-func WatchSignal(shutdown func(), restart func(), reconfig func()) {
-	callback := func(sig os.Signal) {
-		switch sig {
-		case syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP:
-			//shutdown
-			if shutdown != nil {
-				shutdown()
-			}
-		case syscall.SIGUSR1:
-			if restart != nil {
-				restart()
+func (s *Signal) loop(stop chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case sig := <-s.notify:
+			if s.forceExit(sig) {
+				continue
 			}
-		case syscall.SIGUSR2:
-			if reconfig != nil {
-				reconfig()
+			go func() {
+				_ = s.Dispatch(sig)
+			}()
+		}
+	}
+}
+
+//关闭信号处理中再次收到关闭信号时强制退出
+func (s *Signal) forceExit(sig os.Signal) bool {
+	if sig != syscall.SIGINT && sig != syscall.SIGTERM {
+		return false
+	}
+	s.mutex.Lock()
+	stopping := s.stopping
+	s.stopping = true
+	exit := s.exit
+	s.mutex.Unlock()
+	if stopping {
+		log.Printf("[signal] receive %s again, force exit", sig)
+		exit(ForceExitCode)
+	}
+	return stopping
+}
+
+//同步执行信号的处理函数, 返回第一个错误
+func (s *Signal) Dispatch(sig os.Signal) error {
+	s.mutex.Lock()
+	list := append([]*entry{}, s.handlers[sig]...)
+	timeout := s.timeout
+	onError := s.onError
+	s.mutex.Unlock()
+
+	var first error
+	for _, e := range list {
+		if err := run(e.f, sig, timeout); err != nil {
+			onError(sig, err)
+			if first == nil {
+				first = err
 			}
 		}
 	}
-	sig.AddWatchFunc(callback)
-	sig.StartAsync()
+	return first
+}
+
+//带超时执行, 超时后不再等待
+func run(f Handler, sig os.Signal, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- errors.New(fmt.Sprintf("panic: %v", r))
+			}
+		}()
+		done <- f(ctx, sig)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New(fmt.Sprintf("handler not finished after %s", timeout))
+	}
+}
+
+func Handle(sig os.Signal, f Handler) func() {
+	return std.Handle(sig, f)
+}
+
+func SetSignals(signals ...os.Signal) {
+	std.SetSignals(signals...)
+}
+
+func SetTimeout(timeout time.Duration) {
+	std.SetTimeout(timeout)
+}
+
+func Start() {
+	std.Start()
 }
 
 func Stop() {
-	sig.Stop()
+	std.Stop()
+}
+
+func Inject(sig os.Signal) {
+	std.Inject(sig)
 }
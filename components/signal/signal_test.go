@@ -0,0 +1,96 @@
+package sig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignal_Handle(t *testing.T) {
+	s := New()
+	var mutex sync.Mutex
+	order := make([]string, 0)
+	record := func(name string) Handler {
+		return func(ctx context.Context, sig os.Signal) error {
+			mutex.Lock()
+			order = append(order, name)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	s.Handle(syscall.SIGUSR2, record("a"))
+	unregister := s.Handle(syscall.SIGUSR2, record("b"))
+	s.Handle(syscall.SIGUSR1, record("restart"))
+
+	assert.Nil(t, s.Dispatch(syscall.SIGUSR2))
+	assert.Equal(t, []string{"a", "b"}, order)
+
+	order = order[:0]
+	unregister()
+	assert.Nil(t, s.Dispatch(syscall.SIGUSR2))
+	assert.Equal(t, []string{"a"}, order)
+}
+
+func TestSignal_TimeoutAndPanic(t *testing.T) {
+	s := New()
+	s.SetTimeout(20 * time.Millisecond)
+	errs := make([]error, 0)
+	s.OnError(func(sig os.Signal, err error) {
+		errs = append(errs, err)
+	})
+	var called bool
+	s.Handle(syscall.SIGHUP, func(ctx context.Context, sig os.Signal) error {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+		return nil
+	})
+	s.Handle(syscall.SIGHUP, func(ctx context.Context, sig os.Signal) error {
+		panic("boom")
+	})
+	s.Handle(syscall.SIGHUP, func(ctx context.Context, sig os.Signal) error {
+		called = true
+		return errors.New("fail")
+	})
+
+	t1 := time.Now()
+	assert.NotNil(t, s.Dispatch(syscall.SIGHUP))
+	assert.True(t, time.Since(t1) < 500*time.Millisecond, "handler need bound by timeout")
+	assert.True(t, called, "handler need run after panic")
+	assert.Equal(t, 3, len(errs))
+}
+
+func TestSignal_InjectForceExit(t *testing.T) {
+	s := New(syscall.SIGUSR2)
+	exit := make(chan int, 1)
+	s.SetExit(func(code int) {
+		exit <- code
+	})
+	stopping := make(chan struct{})
+	release := make(chan struct{})
+	s.Handle(syscall.SIGTERM, func(ctx context.Context, sig os.Signal) error {
+		close(stopping)
+		<-release
+		return nil
+	})
+	//未开始监听 忽略
+	s.Inject(syscall.SIGTERM)
+
+	s.Start()
+	defer s.Stop()
+	s.Inject(syscall.SIGTERM)
+	<-stopping
+	s.Inject(syscall.SIGTERM)
+	select {
+	case code := <-exit:
+		assert.Equal(t, ForceExitCode, code)
+	case <-time.After(time.Second):
+		t.Fatal("second SIGTERM need force exit")
+	}
+	close(release)
+}
@@ -567,7 +567,10 @@ func (a AlphaDash) GetLimitValue() interface{} {
 	return nil
 }
 
-var emailPattern = regexp.MustCompile(`^[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+(?:\.[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+)*@(?:[\w](?:[\w-]*[\w])?\.)+[a-zA-Z0-9](?:[\w-]*[\w])?$`)
+// EmailExpr is the unanchored email expression, used to find emails in text
+const EmailExpr = `[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+(?:\.[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+)*@(?:[\w](?:[\w-]*[\w])?\.)+[a-zA-Z0-9](?:[\w-]*[\w])?`
+
+var emailPattern = regexp.MustCompile(`^` + EmailExpr + `$`)
 
 // Email check struct
 type Email struct {
@@ -636,8 +639,11 @@ func (b Base64) GetLimitValue() interface{} {
 	return nil
 }
 
+// MobileExpr is the unanchored chinese mobile phone number expression, used to find numbers in text
+const MobileExpr = `((\+86)|(86))?1([356789][0-9]|4[579]|6[67]|7[0135678]|9[189])[0-9]{8}`
+
 // just for chinese mobile phone number
-var mobilePattern = regexp.MustCompile(`^((\+86)|(86))?1([356789][0-9]|4[579]|6[67]|7[0135678]|9[189])[0-9]{8}$`)
+var mobilePattern = regexp.MustCompile(`^` + MobileExpr + `$`)
 
 // Mobile check struct
 type Mobile struct {
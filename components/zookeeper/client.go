@@ -1,6 +1,8 @@
 package zookeeper
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-zookeeper/zk"
@@ -46,6 +48,17 @@ func (zb *ZkBuilder) Restart() error {
 	return zb.Start()
 }
 
+//检查会话状态, 未建立会话返回错误
+func (zb *ZkBuilder) Ping() error {
+	if zb.Conn == nil {
+		return errors.New("zookeeper not connected")
+	}
+	if state := zb.Conn.State(); state != zk.StateHasSession {
+		return errors.New(fmt.Sprintf("zookeeper session state:%s", state.String()))
+	}
+	return nil
+}
+
 //停止
 func (zb *ZkBuilder) Stop() {
 	zb.Conn.Close()
@@ -0,0 +1,224 @@
+package zookeeper
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+
+	"github.com/jeevi-cao/lego/components/config"
+)
+
+//ZooKeeper远程配置源, 实现 config.Provider
+//usage:
+//
+//	文档模式, 节点数据为 toml json yaml 文档
+//	p := NewConfigProvider(zb, "/lego/config", "toml")
+//
+//	节点树模式, format 为空, 子节点路径为配置项, 叶子节点数据为值
+//	/lego/config/mongo/hosts = "127.0.0.1:27017"  =>  mongo.hosts
+//	p := NewConfigProvider(zb, "/lego/config", "")
+//
+//	cf.AddRemote(p, "./runtime/remote_config.json")
+
+//等待会话建立后重新监听的间隔
+const watchRetryInterval = time.Second
+
+//节点操作
+type zkConn interface {
+	Get(path string) ([]byte, *zk.Stat, error)
+	GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error)
+	Children(path string) ([]string, *zk.Stat, error)
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+}
+
+type ConfigProvider struct {
+	conn zkConn
+	//节点路径
+	Path string
+	//文档格式, 为空时使用节点树
+	Format string
+	//读取超时, 默认与会话超时相同
+	Timeout time.Duration
+
+	stop  chan struct{}
+	mutex sync.Mutex
+}
+
+//实例化配置源
+func NewConfigProvider(zb *ZkBuilder, path string, format string) *ConfigProvider {
+	return &ConfigProvider{
+		conn:    zb.Conn,
+		Path:    path,
+		Format:  format,
+		Timeout: zb.Setting.SessionTimeout,
+	}
+}
+
+func (p *ConfigProvider) Name() string {
+	return "zookeeper:" + p.Path
+}
+
+//读取配置, 超时返回错误
+func (p *ConfigProvider) Load() (map[string]interface{}, error) {
+	type result struct {
+		values map[string]interface{}
+		err    error
+	}
+	ch := make(chan *result, 1)
+	go func() {
+		values, err := p.load()
+		ch <- &result{values: values, err: err}
+	}()
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	select {
+	case r := <-ch:
+		return r.values, r.err
+	case <-time.After(timeout):
+		return nil, errors.New(fmt.Sprintf("zookeeper load config path:%s timeout", p.Path))
+	}
+}
+
+func (p *ConfigProvider) load() (map[string]interface{}, error) {
+	if len(p.Format) == 0 {
+		return p.loadTree(p.Path)
+	}
+	data, _, err := p.conn.Get(p.Path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("zookeeper get path:%s error:%s", p.Path, err.Error()))
+	}
+	//不解析密钥引用, 避免明文写入本地缓存
+	values, err := config.ParseData(p.Format, data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("zookeeper parse path:%s error:%s", p.Path, err.Error()))
+	}
+	return values, nil
+}
+
+//读取节点树, 叶子节点为值
+func (p *ConfigProvider) loadTree(node string) (map[string]interface{}, error) {
+	children, _, err := p.conn.Children(node)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("zookeeper children path:%s error:%s", node, err.Error()))
+	}
+	values := make(map[string]interface{}, len(children))
+	for _, child := range children {
+		childPath := path.Join(node, child)
+		grandChildren, _, err := p.conn.Children(childPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("zookeeper children path:%s error:%s", childPath, err.Error()))
+		}
+		if len(grandChildren) > 0 {
+			sub, err := p.loadTree(childPath)
+			if err != nil {
+				return nil, err
+			}
+			values[child] = sub
+			continue
+		}
+		data, _, err := p.conn.Get(childPath)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("zookeeper get path:%s error:%s", childPath, err.Error()))
+		}
+		values[child] = string(data)
+	}
+	return values, nil
+}
+
+//监听节点变化, 节点树模式监听所有节点的数据及子节点变化
+func (p *ConfigProvider) Watch(onChange func()) error {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	if p.stop != nil {
+		return errors.New("zookeeper config provider already watching")
+	}
+	p.stop = make(chan struct{})
+	go p.watch(p.stop, onChange)
+	return nil
+}
+
+//每轮监听使用单独的 round, 重新监听前关闭, 释放未变化节点的转发协程
+func (p *ConfigProvider) watch(stop chan struct{}, onChange func()) {
+	failed := false
+	for {
+		changed := make(chan struct{}, 1)
+		round := make(chan struct{})
+		if err := p.watchNode(p.Path, len(p.Format) == 0, round, changed); err != nil {
+			close(round)
+			//连接不可用 等待后重新监听
+			failed = true
+			select {
+			case <-stop:
+				return
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+		//恢复监听 通知期间可能错过的变化
+		if failed {
+			failed = false
+			onChange()
+		}
+		select {
+		case <-stop:
+			close(round)
+			return
+		case <-changed:
+			close(round)
+			onChange()
+		}
+	}
+}
+
+//注册节点监听, 任一节点变化时写入changed, round 关闭时结束转发
+func (p *ConfigProvider) watchNode(node string, tree bool, round chan struct{}, changed chan struct{}) error {
+	_, _, ch, err := p.conn.GetW(node)
+	if err != nil {
+		return err
+	}
+	forward(ch, round, changed)
+	if !tree {
+		return nil
+	}
+	children, _, ch, err := p.conn.ChildrenW(node)
+	if err != nil {
+		return err
+	}
+	forward(ch, round, changed)
+	for _, child := range children {
+		if err := p.watchNode(path.Join(node, child), tree, round, changed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func forward(ch <-chan zk.Event, round chan struct{}, changed chan struct{}) {
+	go func() {
+		select {
+		case <-ch:
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		case <-round:
+		}
+	}()
+}
+
+//停止监听
+func (p *ConfigProvider) Close() error {
+	defer p.mutex.Unlock()
+	p.mutex.Lock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package zookeeper
+
+import (
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+)
+
+//内存节点
+type testConn struct {
+	nodes   map[string][]byte
+	watches map[string][]chan zk.Event
+	mutex   sync.Mutex
+}
+
+func newTestConn(nodes map[string]string) *testConn {
+	c := &testConn{nodes: make(map[string][]byte), watches: make(map[string][]chan zk.Event)}
+	for p, data := range nodes {
+		c.nodes[p] = []byte(data)
+	}
+	return c
+}
+
+func (c *testConn) Get(p string) ([]byte, *zk.Stat, error) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	data, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return data, &zk.Stat{}, nil
+}
+
+func (c *testConn) GetW(p string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	data, stat, err := c.Get(p)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return data, stat, c.addWatch(p), nil
+}
+
+func (c *testConn) Children(p string) ([]string, *zk.Stat, error) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	children := make([]string, 0)
+	for node := range c.nodes {
+		if path.Dir(node) == p && node != p {
+			children = append(children, path.Base(node))
+		}
+	}
+	sort.Strings(children)
+	return children, &zk.Stat{}, nil
+}
+
+func (c *testConn) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	children, stat, err := c.Children(p)
+	return children, stat, c.addWatch(p), err
+}
+
+func (c *testConn) addWatch(p string) <-chan zk.Event {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	ch := make(chan zk.Event, 1)
+	c.watches[p] = append(c.watches[p], ch)
+	return ch
+}
+
+func (c *testConn) set(p string, data string) {
+	defer c.mutex.Unlock()
+	c.mutex.Lock()
+	c.nodes[p] = []byte(data)
+	for _, ch := range c.watches[p] {
+		ch <- zk.Event{Type: zk.EventNodeDataChanged, Path: p}
+	}
+	delete(c.watches, p)
+}
+
+func TestConfigProvider_Load(t *testing.T) {
+	conn := newTestConn(map[string]string{
+		"/lego/doc":              "[app]\nname = \"remote\"\n",
+		"/lego/tree":             "",
+		"/lego/tree/app":         "",
+		"/lego/tree/app/name":    "tree",
+		"/lego/tree/mongo":       "",
+		"/lego/tree/mongo/hosts": "127.0.0.1:27017",
+	})
+
+	doc := &ConfigProvider{conn: conn, Path: "/lego/doc", Format: "toml"}
+	values, err := doc.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"app": map[string]interface{}{"name": "remote"}}, values)
+
+	tree := &ConfigProvider{conn: conn, Path: "/lego/tree"}
+	values, err = tree.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"app":   map[string]interface{}{"name": "tree"},
+		"mongo": map[string]interface{}{"hosts": "127.0.0.1:27017"},
+	}, values)
+
+	_, err = (&ConfigProvider{conn: conn, Path: "/lego/none", Format: "toml"}).Load()
+	assert.True(t, strings.Contains(err.Error(), "/lego/none"))
+}
+
+func TestConfigProvider_Watch(t *testing.T) {
+	conn := newTestConn(map[string]string{
+		"/lego/tree":          "",
+		"/lego/tree/app":      "",
+		"/lego/tree/app/name": "v1",
+	})
+	p := &ConfigProvider{conn: conn, Path: "/lego/tree"}
+	changed := make(chan struct{}, 10)
+	assert.Nil(t, p.Watch(func() {
+		changed <- struct{}{}
+	}))
+	defer p.Close()
+	assert.NotNil(t, p.Watch(func() {}), "watch twice")
+
+	for _, name := range []string{"v2", "v3"} {
+		assert.Eventually(t, func() bool {
+			conn.mutex.Lock()
+			defer conn.mutex.Unlock()
+			return len(conn.watches["/lego/tree/app/name"]) > 0
+		}, time.Second, 5*time.Millisecond)
+		conn.set("/lego/tree/app/name", name)
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Fatal("node change not notify")
+		}
+	}
+}
+
+func TestConfigProvider_WatchGoroutines(t *testing.T) {
+	nodes := map[string]string{"/lego/tree": ""}
+	for i := 0; i < 10; i++ {
+		nodes["/lego/tree/app/key"+strconv.Itoa(i)] = "v"
+	}
+	nodes["/lego/tree/app"] = ""
+	conn := newTestConn(nodes)
+	p := &ConfigProvider{conn: conn, Path: "/lego/tree"}
+	changed := make(chan struct{}, 10)
+	assert.Nil(t, p.Watch(func() {
+		changed <- struct{}{}
+	}))
+	defer p.Close()
+	watching := func() bool {
+		conn.mutex.Lock()
+		defer conn.mutex.Unlock()
+		return len(conn.watches["/lego/tree/app/key0"]) > 0
+	}
+	assert.Eventually(t, watching, time.Second, 5*time.Millisecond)
+	base := runtime.NumGoroutine()
+
+	//未变化节点的转发协程在重新监听前释放
+	for i := 0; i < 20; i++ {
+		conn.set("/lego/tree/app/key0", strconv.Itoa(i))
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Fatal("node change not notify")
+		}
+		assert.Eventually(t, watching, time.Second, 5*time.Millisecond)
+	}
+	assert.Eventually(t, func() bool {
+		//首轮转发协程可能未全部启动, 允许少量误差, 泄漏时每次变化增加约节点数的两倍
+		return runtime.NumGoroutine() <= base+4
+	}, time.Second, 10*time.Millisecond, "watch goroutines leak")
+}
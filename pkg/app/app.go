@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -10,9 +11,11 @@ import (
 
 	"github.com/jeevi-cao/lego/components/config"
 	"github.com/jeevi-cao/lego/components/crontab"
+	"github.com/jeevi-cao/lego/components/health"
 	"github.com/jeevi-cao/lego/components/httpserver"
 	"github.com/jeevi-cao/lego/components/log"
 	"github.com/jeevi-cao/lego/components/mongo"
+	"github.com/jeevi-cao/lego/components/pidfile"
 	"github.com/jeevi-cao/lego/components/zookeeper"
 )
 
@@ -28,6 +31,9 @@ const (
 var defaultInstance = "app"
 var multiInstanceSign = "multi"
 
+//配置覆盖环境变量默认前缀
+var defaultEnvPrefix = "LEGO"
+
 //环境变量
 var envName2Num = map[string]uint8{
 	"develop": DEVELOP,
@@ -71,11 +77,20 @@ type Application struct {
 	Env uint8
 	//配置路径
 	CfgFile string
+	//配置覆盖环境变量前缀
+	EnvPrefix string
 	//
 	RequestId string
 	//组件配置
 	Components *Components
 
+	//自定义组件工厂
+	factories    map[string]ComponentFactory
+	factoryNames []string
+
+	//被持有的组件句柄引用计数
+	refs map[interface{}]*refCount
+
 	mutex *sync.Mutex
 }
 
@@ -113,17 +128,36 @@ type Components struct {
 		handler map[string]*zookeeper.ZkBuilder
 		enable  bool
 	}
+	//健康检查
+	health struct {
+		handler *health.Health
+		enable  bool
+	}
+	//pid文件
+	pidfile struct {
+		handler *pidfile.PidFile
+		enable  bool
+	}
+	//自定义组件 name => instance => component
+	custom map[string]map[string]Component
 }
 
 func init() {
 	Once.Do(func() {
-		App = &Application{
-			Components: &Components{},
-			mutex:      new(sync.Mutex),
-		}
+		App = NewApplication()
 	})
 }
 
+//实例化Application, App 为默认实例
+//测试等场景可创建相互隔离的实例
+func NewApplication() *Application {
+	return &Application{
+		EnvPrefix:  defaultEnvPrefix,
+		Components: &Components{},
+		mutex:      new(sync.Mutex),
+	}
+}
+
 func (a *Application) SetName(name string) {
 	a.Name = name
 }
@@ -177,6 +211,15 @@ func (a *Application) GetCfgFile() (string, error) {
 	return a.CfgFile, nil
 }
 
+//配置覆盖环境变量前缀, 为空时不读取环境变量
+func (a *Application) SetEnvPrefix(prefix string) {
+	a.EnvPrefix = prefix
+}
+
+func (a *Application) GetEnvPrefix() string {
+	return a.EnvPrefix
+}
+
 func (a *Application) SetRequestId(reqid string) {
 	a.RequestId = reqid
 }
@@ -202,7 +245,7 @@ func (a *Application) GetConfig() (cf *config.Config, err error) {
 
 func (a *Application) GetConfiger() *viper.Viper {
 	cfg, _ := a.GetConfig()
-	return cfg.Handler
+	return cfg.GetHandler()
 }
 
 //log 支持多实例
@@ -226,6 +269,8 @@ func (a *Application) SetLog(instance string, lg *log.Log) {
 }
 
 func (a *Application) GetLog(instance string) (*log.Log, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
 	if a.Components.log.enable == false {
 		return nil, errors.New("not init log")
 	}
@@ -239,11 +284,33 @@ func (a *Application) GetLog(instance string) (*log.Log, error) {
 	return hd, nil
 }
 
+func (a *Application) GetAllLog() (map[string]*log.Log, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	if a.Components.log.enable == false {
+		return nil, errors.New("not init log")
+	}
+	logs := make(map[string]*log.Log, len(a.Components.log.handler))
+	for instance, l := range a.Components.log.handler {
+		logs[instance] = l
+	}
+	return logs, nil
+}
+
+//日志未初始化时返回logrus默认logger
 func (a *Application) GetLogger(instance string) *logrus.Logger {
-	l, _ := a.GetLog(instance)
+	l, err := a.GetLog(instance)
+	if err != nil {
+		return logrus.StandardLogger()
+	}
 	return l.Logger
 }
 
+//获取携带 ctx 中请求字段(request id, trace id 等)的日志
+func (a *Application) GetContextLogger(ctx context.Context, instance string) *logrus.Entry {
+	return log.WithContext(ctx, a.GetLogger(instance))
+}
+
 //crontab
 func (a *Application) SetCrontab(cron *crontab.Crontab) {
 	a.Components.crontab = struct {
@@ -293,6 +360,12 @@ func (a *Application) SetMongo(instance string, mg *mongo.Mongo) {
 }
 
 func (a *Application) GetMongo(instance string) (*mongo.Mongo, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	return a.getMongo(instance)
+}
+
+func (a *Application) getMongo(instance string) (*mongo.Mongo, error) {
 	if a.Components.mongo.enable == false {
 		return nil, errors.New("not init mongo")
 	}
@@ -306,11 +379,58 @@ func (a *Application) GetMongo(instance string) (*mongo.Mongo, error) {
 	return mg, nil
 }
 
+//获取并持有mongo实例, 使用完成后调用 release
+//持有期间实例被重新加载替换时, 旧实例在释放后关闭
+//usage:
+//	mg, release, err := a.AcquireMongo("db1")
+//	if err != nil {
+//	}
+//	defer release()
+func (a *Application) AcquireMongo(instance string) (*mongo.Mongo, func(), error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	mg, err := a.getMongo(instance)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mg, a.retain(mg), nil
+}
+
+//替换mongo实例, mg 为空时删除实例
+//旧实例没有被持有时立即关闭, 否则在最后一次释放时关闭
+func (a *Application) ReplaceMongo(instance string, mg *mongo.Mongo) {
+	a.mutex.Lock()
+	if instance == "" {
+		instance = defaultInstance
+	}
+	old := a.Components.mongo.handler[instance]
+	if mg == nil {
+		delete(a.Components.mongo.handler, instance)
+	} else {
+		if a.Components.mongo.handler == nil {
+			a.Components.mongo.handler = make(map[string]*mongo.Mongo)
+		}
+		a.Components.mongo.handler[instance] = mg
+		a.Components.mongo.enable = true
+	}
+	closeNow := old != nil && old != mg && a.retire(old, old.Close)
+	a.mutex.Unlock()
+	if closeNow {
+		old.Close()
+	}
+}
+
 func (a *Application) GetAllMongo() (map[string]*mongo.Mongo, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
 	if a.Components.mongo.enable == false {
 		return nil, errors.New("not init mongo")
 	}
-	return a.Components.mongo.handler, nil
+	mongos := make(map[string]*mongo.Mongo, len(a.Components.mongo.handler))
+	for instance, mg := range a.Components.mongo.handler {
+		mongos[instance] = mg
+	}
+	return mongos, nil
 }
 
 //zookeeper
@@ -354,6 +474,35 @@ func (a *Application) GetAllZookeeper() (map[string]*zookeeper.ZkBuilder, error)
 	return a.Components.zookeeper.handler, nil
 }
 
+//health
+func (a *Application) SetHealth(h *health.Health) {
+	a.Components.health = struct {
+		handler *health.Health
+		enable  bool
+	}{handler: h, enable: true}
+}
+
+func (a *Application) GetHealth() (*health.Health, error) {
+	if a.Components.health.enable == false {
+		return nil, errors.New("not init health")
+	}
+	return a.Components.health.handler, nil
+}
+
+func (a *Application) SetPidFile(p *pidfile.PidFile) {
+	a.Components.pidfile = struct {
+		handler *pidfile.PidFile
+		enable  bool
+	}{handler: p, enable: true}
+}
+
+func (a *Application) GetPidFile() (*pidfile.PidFile, error) {
+	if a.Components.pidfile.enable == false {
+		return nil, errors.New("not init pid file")
+	}
+	return a.Components.pidfile.handler, nil
+}
+
 func (a *Application) Close() {
 	a.Components = &Components{}
 }
@@ -3,9 +3,13 @@ package app
 import (
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/jeevi-cao/lego/components/config"
+	"github.com/jeevi-cao/lego/components/mongo"
 )
 
 func TestApplication_GetConfig(t *testing.T) {
@@ -13,3 +17,105 @@ func TestApplication_GetConfig(t *testing.T) {
 	assert.Equal(t, cf,  (*config.Config)(nil), "config need equal nil")
 	assert.NotEqual(t, err, nil, "not init config")
 }
+
+type testComponent struct {
+	started bool
+}
+
+func (c *testComponent) Name() string                { return "test" }
+func (c *testComponent) Init(cfg *viper.Viper) error { return nil }
+func (c *testComponent) Start() error                { c.started = true; return nil }
+func (c *testComponent) Stop() error                 { c.started = false; return nil }
+func (c *testComponent) Health() error               { return nil }
+
+func TestApplication_RegisterComponent(t *testing.T) {
+	a := NewApplication()
+	factory := func() Component { return &testComponent{} }
+	assert.Nil(t, a.RegisterComponent("test", factory))
+	assert.NotNil(t, a.RegisterComponent("test", factory), "register twice need error")
+	assert.Equal(t, []string{"test"}, a.GetComponentNames())
+
+	_, err := a.GetComponent("test", "")
+	assert.NotNil(t, err, "not set component")
+
+	a.SetComponent("test", "", factory())
+	a.SetComponent("test", "db1", factory())
+	c, err := a.GetComponent("test", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "test", c.Name())
+
+	all, err := a.GetAllComponent("test")
+	assert.Nil(t, err)
+	assert.Len(t, all, 2)
+
+	a.Close()
+	_, err = a.GetComponent("test", "db1")
+	assert.NotNil(t, err, "component need clear after close")
+}
+
+func TestNewApplication(t *testing.T) {
+	a1 := NewApplication()
+	a2 := NewApplication()
+	a1.SetName("a1")
+	assert.Nil(t, a2.SetEnv("test"))
+	assert.NotEqual(t, a1.Name, a2.Name)
+	assert.False(t, a1.IsTest())
+	assert.True(t, a2.IsTest())
+	assert.NotEqual(t, App, a1, "new application need not default")
+}
+
+func TestApplication_ReplaceMongo(t *testing.T) {
+	a := NewApplication()
+	newMongo := func() *mongo.Mongo {
+		cli, err := mongodriver.NewClient(options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+		assert.Nil(t, err)
+		return &mongo.Mongo{Client: cli}
+	}
+	old := newMongo()
+	a.SetMongo("db1", old)
+
+	mg, release, err := a.AcquireMongo("db1")
+	assert.Nil(t, err)
+	assert.Equal(t, old, mg)
+	assert.Equal(t, 1, a.countRefs(old))
+
+	//替换后新的使用方获取新实例, 已持有的使用方保持旧实例
+	current := newMongo()
+	a.ReplaceMongo("db1", current)
+	mg, _ = a.GetMongo("db1")
+	assert.Equal(t, current, mg)
+	assert.Equal(t, 1, a.countRefs(old))
+
+	release()
+	release()
+	assert.Equal(t, 0, a.countRefs(old))
+
+	a.ReplaceMongo("db1", nil)
+	_, err = a.GetMongo("db1")
+	assert.NotNil(t, err)
+	_, _, err = a.AcquireMongo("db2")
+	assert.NotNil(t, err)
+}
+
+func TestApplication_RefCount(t *testing.T) {
+	a := NewApplication()
+	h := new(int)
+	var closed int
+	closer := func() { closed++ }
+
+	a.mutex.Lock()
+	r1 := a.retain(h)
+	r2 := a.retain(h)
+	assert.False(t, a.retire(h, closer), "held handle need close after release")
+	a.mutex.Unlock()
+
+	r1()
+	assert.Equal(t, 0, closed)
+	r2()
+	assert.Equal(t, 1, closed)
+
+	//没有持有 立即关闭
+	a.mutex.Lock()
+	assert.True(t, a.retire(new(int), closer))
+	a.mutex.Unlock()
+}
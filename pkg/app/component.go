@@ -0,0 +1,121 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+//可插拔组件
+//usage:
+//
+//	type Redis struct { ... }
+//	func (r *Redis) Name() string { return "redis" }
+//	func (r *Redis) Init(cfg *viper.Viper) error { ... }
+//	...
+//
+//	app.App.RegisterComponent("redis", func() app.Component { return &Redis{} })
+//
+//	//bootstarp.Init 根据配置 [redis] 或 [redis.instance.xxx] 初始化实例
+//	r, err := app.App.GetComponent("redis", "")
+type Component interface {
+	//组件名称
+	Name() string
+	//根据组件配置子树初始化
+	Init(cfg *viper.Viper) error
+	//启动
+	Start() error
+	//停止
+	Stop() error
+	//健康检查
+	Health() error
+}
+
+//组件工厂 每个实例调用一次
+type ComponentFactory func() Component
+
+//注册组件工厂, 组件名称对应配置节点名称
+func (a *Application) RegisterComponent(name string, factory ComponentFactory) error {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	if len(name) < 1 || factory == nil {
+		return errors.New("register component need name and factory")
+	}
+	if a.factories == nil {
+		a.factories = make(map[string]ComponentFactory)
+	}
+	if _, ok := a.factories[name]; ok {
+		return errors.New(fmt.Sprintf("component:%s already registered", name))
+	}
+	a.factories[name] = factory
+	a.factoryNames = append(a.factoryNames, name)
+	return nil
+}
+
+//获取组件工厂
+func (a *Application) GetComponentFactory(name string) (ComponentFactory, error) {
+	factory, ok := a.factories[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("component:%s not registered", name))
+	}
+	return factory, nil
+}
+
+//按注册顺序返回组件名称
+func (a *Application) GetComponentNames() []string {
+	names := make([]string, len(a.factoryNames))
+	copy(names, a.factoryNames)
+	return names
+}
+
+//设置组件实例 支持多实例
+func (a *Application) SetComponent(name string, instance string, c Component) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	if a.Components.custom == nil {
+		a.Components.custom = make(map[string]map[string]Component)
+	}
+	if instance == "" {
+		instance = defaultInstance
+	}
+	if _, ok := a.Components.custom[name]; !ok {
+		a.Components.custom[name] = make(map[string]Component)
+	}
+	a.Components.custom[name][instance] = c
+}
+
+func (a *Application) GetComponent(name string, instance string) (Component, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	instances, ok := a.Components.custom[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("not init %s", name))
+	}
+	if instance == "" {
+		instance = defaultInstance
+	}
+	c, ok := instances[instance]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s not exists", name))
+	}
+	return c, nil
+}
+
+func (a *Application) GetAllComponent(name string) (map[string]Component, error) {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+
+	instances, ok := a.Components.custom[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("not init %s", name))
+	}
+	all := make(map[string]Component, len(instances))
+	for instance, c := range instances {
+		all[instance] = c
+	}
+	return all, nil
+}
@@ -0,0 +1,71 @@
+package app
+
+import "sync"
+
+//组件句柄引用计数
+//配置重新加载时替换的旧句柄, 在所有使用方释放后关闭
+type refCount struct {
+	count int
+	//已被替换, 引用归零时关闭
+	closer func()
+}
+
+//增加引用, 返回释放函数, 调用方持有 a.mutex
+func (a *Application) retain(h interface{}) func() {
+	if a.refs == nil {
+		a.refs = make(map[interface{}]*refCount)
+	}
+	r, ok := a.refs[h]
+	if !ok {
+		r = &refCount{}
+		a.refs[h] = r
+	}
+	r.count++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.release(h)
+		})
+	}
+}
+
+//释放引用, 已被替换且引用归零时关闭
+func (a *Application) release(h interface{}) {
+	a.mutex.Lock()
+	r, ok := a.refs[h]
+	if !ok {
+		a.mutex.Unlock()
+		return
+	}
+	r.count--
+	var closer func()
+	if r.count <= 0 {
+		delete(a.refs, h)
+		closer = r.closer
+	}
+	a.mutex.Unlock()
+	if closer != nil {
+		closer()
+	}
+}
+
+//标记句柄已被替换, 返回 true 时没有引用 由调用方在释放锁后关闭
+//调用方持有 a.mutex
+func (a *Application) retire(h interface{}, closer func()) bool {
+	r, ok := a.refs[h]
+	if ok && r.count > 0 {
+		r.closer = closer
+		return false
+	}
+	return true
+}
+
+//句柄当前引用数
+func (a *Application) countRefs(h interface{}) int {
+	defer a.mutex.Unlock()
+	a.mutex.Lock()
+	if r, ok := a.refs[h]; ok {
+		return r.count
+	}
+	return 0
+}
@@ -0,0 +1,168 @@
+package bootstarp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//管理接口
+//	[admin]
+//	enable = true
+//	prefix = "/admin"
+//	token = "xxx"  必须设置, 请求需携带 X-Admin-Token 头
+//
+//	GET  /admin/log/level                                     各日志实例当前级别
+//	PUT  /admin/log/level?instance=app&level=debug&duration=10m 修改级别, duration 为空则永久修改
+
+const adminTokenHeader = "X-Admin-Token"
+
+//日志级别信息
+type logLevel struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+//初始化管理接口
+func InitAdmin(a *app.Application) error {
+	c, _ := a.GetConfig()
+	s := &adminSetting{}
+	if err := c.Bind("admin", s); err != nil {
+		return err
+	}
+	if !s.Enable {
+		return nil
+	}
+	//管理接口挂载在服务端口上, 未设置 token 时不开启
+	if len(s.Token) == 0 {
+		return errors.New("admin need token")
+	}
+	hs, _ := a.GetHttpServer()
+	if hs == nil {
+		return errors.New("admin need http server")
+	}
+	prefix := s.Prefix
+	if len(prefix) == 0 {
+		prefix = "/admin"
+	}
+	group := hs.Engine.Group(prefix, adminAuth(s.Token))
+	group.GET("/log/level", getLogLevelHandler(a))
+	group.PUT("/log/level", setLogLevelHandler(a))
+
+	a.GetLogger("").Info("[init] admin component complete !")
+	return nil
+}
+
+//token 校验
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(token) == 0 || c.GetHeader(adminTokenHeader) != token {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func getLogLevelHandler(a *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logs, err := a.GetAllLog()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		levels := make(map[string]*logLevel, len(logs))
+		for instance, l := range logs {
+			level := &logLevel{Level: l.GetLevel()}
+			if revertAt := l.GetRevertAt(); !revertAt.IsZero() {
+				level.RevertAt = &revertAt
+			}
+			levels[instance] = level
+		}
+		c.JSON(http.StatusOK, levels)
+	}
+}
+
+func setLogLevelHandler(a *app.Application) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		instance := c.Query("instance")
+		level := c.Query("level")
+		var duration time.Duration
+		if d := c.Query("duration"); len(d) > 0 {
+			var err error
+			if duration, err = time.ParseDuration(d); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid duration:%s", d)})
+				return
+			}
+		}
+		l, err := a.GetLog(instance)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err := l.SetLevel(level, duration); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a.GetLogger("").Warnf("[admin] log instance:%s level change to:%s duration:%s", instance, level, duration)
+		c.JSON(http.StatusOK, &logLevel{Level: l.GetLevel()})
+	}
+}
+
+//重新读取配置文件, 变更通过订阅生效, 用于SIGUSR2
+func ReloadConfig(a *app.Application) error {
+	c, err := a.GetConfig()
+	if err != nil {
+		return err
+	}
+	if err := c.Reload(); err != nil {
+		return errors.New(fmt.Sprintf("reload config error:%s", err.Error()))
+	}
+	return nil
+}
+
+//按当前配置应用各日志实例的级别, 配置的级别未变化时保留管理接口临时修改的级别
+func ApplyLogLevel(a *app.Application) error {
+	c, err := a.GetConfig()
+	if err != nil {
+		return err
+	}
+	settings, err := bindLog(c)
+	if err != nil {
+		return err
+	}
+	logs, err := a.GetAllLog()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for instance, l := range logs {
+		s, ok := settings[instance]
+		if !ok {
+			s, ok = settings[""]
+		}
+		if !ok || len(s.Level) == 0 {
+			continue
+		}
+		level := s.Level
+		changed, err := l.ApplyLevel(level)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("instance:%s error:%s", instance, err.Error()))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		a.GetLogger("").Infof("[reload] log instance:%s level:%s", instance, level)
+	}
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("reload log level failed: %s", strings.Join(failed, "; ")))
+	}
+	return nil
+}
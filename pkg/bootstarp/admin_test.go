@@ -0,0 +1,105 @@
+package bootstarp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+const adminConfig = `
+[app]
+name = "admin"
+[httpserver]
+http_host = "127.0.0.1"
+http_port = 0
+[log]
+level = "info"
+[admin]
+enable = true
+token = "secret"
+`
+
+func TestAdmin_LogLevel(t *testing.T) {
+	a := app.NewApplication()
+	filename := writeConfig(t, adminConfig)
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+	hs, _ := a.GetHttpServer()
+
+	do := func(method string, path string, token string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set(adminTokenHeader, token)
+		hs.Engine.ServeHTTP(w, req)
+		body := make(map[string]interface{})
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, _ := do("GET", "/admin/log/level", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := do("PUT", "/admin/log/level?level=debug&duration=1m", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "debug", body["level"])
+
+	code, body = do("GET", "/admin/log/level", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	level := body["app"].(map[string]interface{})
+	assert.Equal(t, "debug", level["level"])
+	assert.NotNil(t, level["revert_at"])
+
+	code, _ = do("PUT", "/admin/log/level?level=unknown", "secret")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = do("PUT", "/admin/log/level?instance=none&level=debug", "secret")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	//其它日志配置变化时保留临时级别
+	config := strings.Replace(adminConfig, "level = \"info\"", "level = \"info\"\nformat = \"json\"", 1)
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(config), 0644))
+	assert.Nil(t, ReloadConfig(a))
+	l, _ := a.GetLog("")
+	assert.Equal(t, "debug", l.GetLevel())
+	assert.False(t, l.GetRevertAt().IsZero())
+
+	//配置的级别变化时覆盖临时级别
+	config = strings.Replace(config, "level = \"info\"", "level = \"error\"", 1)
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(config), 0644))
+	assert.Nil(t, ReloadConfig(a))
+	assert.Equal(t, "error", l.GetLevel())
+	assert.True(t, l.GetRevertAt().IsZero())
+}
+
+func TestAdmin_NeedToken(t *testing.T) {
+	config := strings.Replace(adminConfig, "token = \"secret\"", "token = \"\"", 1)
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, config))
+	b := New(a)
+	err := b.Init()
+	defer b.Shutdown()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "admin.token")
+}
+
+func TestReloadConfig(t *testing.T) {
+	a := app.NewApplication()
+	filename := writeConfig(t, "[log]\nlevel = \"info\"\n")
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("[log]\nlevel = \"error\"\n"), 0644))
+	assert.Nil(t, ReloadConfig(a))
+	l, _ := a.GetLog("")
+	assert.Equal(t, "error", l.GetLevel())
+}
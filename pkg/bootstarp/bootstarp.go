@@ -1,37 +1,235 @@
 package bootstarp
 
-import "github.com/jeevi-cao/lego/pkg/app"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
 
-var StopChan = make(chan struct{})
+	"github.com/jeevi-cao/lego/components/graceful"
+	sig "github.com/jeevi-cao/lego/components/signal"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
 
-func Start() {
+//启动器 绑定一个Application
+//usage:
+//
+//	a := app.NewApplication()
+//	a.SetCfgFile("./config.toml")
+//	b := New(a)
+//	if err := b.Init(); err != nil {
+//	}
+//	defer b.Shutdown()
+//	if err := b.Start(); err != nil {
+//	}
+type Bootstrap struct {
+	App *app.Application
+	//初始化步骤
+	initSteps *steps
+	//关闭步骤
+	shutdownSteps *steps
+	//停止信号
+	stopChan chan struct{}
+	//系统信号, 为空时不监听
+	signal *sig.Signal
+	//取消注册的信号处理函数
+	unregisters []func()
+	//配置绑定
+	bindings []*binding
+	//最近一次关闭的错误及报告
+	shutdownErr    error
+	shutdownReport ShutdownReport
+	mutex          sync.Mutex
+	//生命周期事件函数
+	hooks hooks
+	//后台任务
+	background       sync.WaitGroup
+	backgroundCtx    context.Context
+	backgroundCancel context.CancelFunc
+	//Init Start Shutdown 互斥, 信号触发的关闭等待启动完成
+	lifecycle sync.Mutex
+	//http服务异常退出的错误
+	serveErr <-chan error
+}
+
+//配置绑定项
+type binding struct {
+	key string
+	out interface{}
+}
+
+//停止信号, 缓冲1 Run未等待时不阻塞Stop
+var StopChan = make(chan struct{}, 1)
+
+//默认启动器 绑定app.App 并监听系统信号
+var std = newDefault()
+
+func newDefault() *Bootstrap {
+	b := New(app.App)
+	b.stopChan = StopChan
+	b.signal = sig.Default()
+	return b
+}
+
+//实例化启动器, 不监听系统信号
+func New(a *app.Application) *Bootstrap {
+	b := &Bootstrap{
+		App:      a,
+		stopChan: make(chan struct{}, 1),
+	}
+	b.backgroundCtx, b.backgroundCancel = context.WithCancel(context.Background())
+	b.initSteps = newInitSteps(b)
+	b.shutdownSteps = newShutdownSteps(b)
+	return b
+}
+
+//设置监听的系统信号, Init 时注册处理函数并开始监听
+//测试时可使用 Inject 模拟收到信号
+func (b *Bootstrap) SetSignal(s *sig.Signal) {
+	b.signal = s
+}
+
+//默认启动器
+func Default() *Bootstrap {
+	return std
+}
+
+//启动服务, BeforeStart AfterStart 事件函数失败时返回错误, 不标记就绪
+//返回错误时已启动的组件需调用 Shutdown 关闭
+func (b *Bootstrap) Start() error {
+	//只输出路由
+	if isRoutesMode() {
+		b.PrintRoutes(os.Stdout)
+		return nil
+	}
+	defer b.lifecycle.Unlock()
+	b.lifecycle.Lock()
+	ctx := b.backgroundCtx
+	if err := b.runHooks(ctx, EventBeforeStart); err != nil {
+		return err
+	}
 	//启动httpserver
-	hs, _ := app.App.GetHttpServer()
+	hs, _ := b.App.GetHttpServer()
 	if hs != nil {
-		hs.ServerRun()
+		if err := hs.ServerRun(); err != nil {
+			return err
+		}
+		b.serveErr = hs.Err()
 	}
 	//crontab
-	cron, _ := app.App.GetCrontab()
+	cron, _ := b.App.GetCrontab()
 	if cron != nil {
 		cron.StartAsync()
 	}
+	//自定义组件
+	StartComponents(b.App)
+	if err := b.runHooks(ctx, EventAfterStart); err != nil {
+		return err
+	}
+	//启动完成 就绪
+	if h, _ := b.App.GetHealth(); h != nil {
+		h.SetReady(true)
+	}
+	//平滑重启时通知父进程退出
+	if err := graceful.Ready(); err != nil {
+		b.App.GetLogger("").Errorf("[start] %s", err.Error())
+	}
+	return nil
 }
 
-//关闭服务
-func Stop(stop bool) {
-	Shutdown()
+//启动自定义组件
+func StartComponents(a *app.Application) {
+	for _, name := range a.GetComponentNames() {
+		components, _ := a.GetAllComponent(name)
+		for instance, c := range components {
+			if err := c.Start(); err != nil {
+				a.GetLogger("").Errorf("[start] %s instance:%s error:%s", name, instance, err.Error())
+			}
+		}
+	}
+}
+
+//关闭服务, stop 为 true 时通知 Run 返回
+func (b *Bootstrap) Stop(stop bool) {
+	b.Shutdown()
 	if stop == true {
-		StopChan <- struct{}{}
+		select {
+		case b.stopChan <- struct{}{}:
+		default:
+		}
 	}
 
 }
 
+//平滑重启, 以相同参数启动新进程并传递http监听和pid文件锁
+//新进程就绪后关闭当前进程, 处理完已接收的请求后 Run 返回
+//新进程启动失败时当前进程继续运行
+func (b *Bootstrap) Restart() {
+	pid, err := b.fork()
+	if err != nil {
+		b.App.GetLogger("").Errorf("[restart] %s", err.Error())
+		return
+	}
+	b.App.GetLogger("").Infof("[restart] new process pid:%d ready, shutdown", pid)
+	b.Stop(true)
+}
+
+//启动新进程并等待就绪
+func (b *Bootstrap) fork() (int, error) {
+	defer b.lifecycle.Unlock()
+	b.lifecycle.Lock()
+	files := make(map[string]*os.File)
+	if hs, _ := b.App.GetHttpServer(); hs != nil && hs.Listener != nil {
+		f, err := hs.ListenerFile()
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		files["httpserver"] = f
+	}
+	if p, _ := b.App.GetPidFile(); p != nil && p.LockFile() != nil {
+		files["pid"] = p.LockFile()
+	}
+	pid, err := graceful.Fork(files, graceful.DefaultTimeout)
+	if err != nil {
+		return pid, errors.New(fmt.Sprintf("fork new process error:%s", err.Error()))
+	}
+	return pid, nil
+}
+
+//启动并等待停止信号, 启动失败或http服务异常退出时返回错误
+func (b *Bootstrap) Run() error {
+	if err := b.Start(); err != nil {
+		return err
+	}
+	if isRoutesMode() {
+		return nil
+	}
+	select {
+	case <-b.stopChan:
+	case err, ok := <-b.serveErr:
+		if ok {
+			return err
+		}
+		//正常关闭时等待停止信号
+		<-b.stopChan
+	}
+	return nil
+}
+
+func Start() error {
+	return std.Start()
+}
+
+func Stop(stop bool) {
+	std.Stop(stop)
+}
+
 func Restart() {
-	Stop(false)
-	Start()
+	std.Restart()
 }
 
-func Run() {
-	Start()
-	<-StopChan
+func Run() error {
+	return std.Run()
 }
@@ -0,0 +1,328 @@
+package bootstarp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/config"
+	"github.com/jeevi-cao/lego/components/health"
+	sig "github.com/jeevi-cao/lego/components/signal"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//写入测试配置文件
+func writeConfig(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal("write config error:", err)
+	}
+	return filename
+}
+
+func newTestBootstrap(t *testing.T, name string) *Bootstrap {
+	a := app.NewApplication()
+	_ = a.SetEnv("test")
+	a.SetCfgFile(writeConfig(t, "[app]\nname = \""+name+"\"\n"))
+	return New(a)
+}
+
+func TestBootstrap_Isolated(t *testing.T) {
+	for _, name := range []string{"app1", "app2"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			b := newTestBootstrap(t, name)
+			assert.Nil(t, b.Init())
+			assert.Equal(t, name, b.App.Name)
+			assert.NotEqual(t, app.App, b.App)
+
+			b.Shutdown()
+			_, err := b.App.GetConfig()
+			assert.NotNil(t, err, "config need clear after shutdown")
+		})
+	}
+}
+
+func TestBootstrap_InitError(t *testing.T) {
+	a := app.NewApplication()
+	a.SetCfgFile(filepath.Join(t.TempDir(), "not_exists.toml"))
+	b := New(a)
+	assert.Nil(t, b.RegisterInitStep("dao", func() error { return nil }, "log"))
+
+	err := b.Init()
+	errs, ok := err.(InitErrors)
+	assert.True(t, ok, "error need InitErrors")
+	//config 失败 依赖的步骤全部跳过
+	assert.Equal(t, "config", errs[0].Component)
+	assert.Len(t, errs, len(b.initSteps.names()))
+
+	b.Shutdown()
+}
+
+func TestBootstrap_Health(t *testing.T) {
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, "[app]\nname = \"health\"\n[health]\nenable = true\n"))
+	b := New(a)
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.RegisterHealthCheck("custom", func(ctx context.Context) error { return nil }))
+
+	h, err := a.GetHealth()
+	assert.Nil(t, err)
+	assert.False(t, h.IsReady(), "not ready before start")
+
+	assert.Nil(t, b.Start())
+	assert.True(t, h.IsReady())
+	assert.Equal(t, health.StatusUp, h.Check(context.Background()).Status)
+
+	b.Shutdown()
+	assert.False(t, h.IsReady(), "not ready after shutdown")
+}
+
+func TestBootstrap_BindConfig(t *testing.T) {
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, `
+[app]
+name = "bind"
+[log]
+level = "verbose"
+async_policy = "drop"
+[log.kafka]
+enable = true
+hosts = "127.0.0.1:9092"
+required_acks = 2
+[log.syslog]
+enable = true
+facility = "local9"
+[log.redact]
+enable = true
+mode = "mask"
+[mongo]
+type = "multi"
+[mongo.instance.db1]
+max_pool_size = 10
+min_pool_size = 20
+[service]
+timeout = 0
+`))
+	b := New(a)
+	var service struct {
+		Url     string `mapstructure:"url" valid:"Required"`
+		Timeout int    `mapstructure:"timeout" valid:"Min(1)"`
+	}
+	b.BindConfig("service", &service)
+
+	err := b.Init()
+	errs, ok := err.(InitErrors)
+	assert.True(t, ok, "error need InitErrors")
+	assert.Equal(t, "bind", errs[0].Component)
+	bindErrs, ok := errs[0].Err.(config.BindErrors)
+	assert.True(t, ok, "error need BindErrors")
+	keys := make([]string, 0)
+	for _, e := range bindErrs {
+		keys = append(keys, e.Key)
+	}
+	assert.Equal(t, []string{
+		"log.level",
+		"log.async_policy",
+		"log.kafka.required_acks",
+		"log.syslog.facility",
+		"log.redact.mode",
+		"mongo.instance.db1.hosts",
+		"mongo.instance.db1.min_pool_size",
+		"service.url",
+		"service.timeout",
+	}, keys)
+
+	b.Shutdown()
+}
+
+func TestBootstrap_HotReload(t *testing.T) {
+	a := app.NewApplication()
+	filename := writeConfig(t, `
+[log]
+level = "info"
+[httpserver]
+http_host = "127.0.0.1"
+http_port = 0
+middleware = ["cors"]
+`)
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+
+	hs, _ := a.GetHttpServer()
+	l, _ := a.GetLog("")
+	assert.Equal(t, []string{"cors"}, hs.GetEnableMiddleware())
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(`
+[log]
+level = "error"
+[httpserver]
+http_host = "127.0.0.1"
+http_port = 0
+middleware = ["requestid", "ydlogger"]
+`), 0644))
+	assert.Nil(t, ReloadConfig(a))
+	assert.Equal(t, "error", l.GetLevel())
+	assert.Equal(t, []string{"requestid", "ydlogger"}, hs.GetEnableMiddleware())
+
+	//不合法配置 拒绝加载
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("[log]\nlevel = \"verbose\"\n"), 0644))
+	assert.NotNil(t, ReloadConfig(a))
+	assert.Equal(t, "error", l.GetLevel())
+	c, _ := a.GetConfig()
+	assert.Equal(t, "error", c.GetHandler().GetString("log.level"))
+}
+
+func TestBootstrap_EnvConfig(t *testing.T) {
+	assert.Nil(t, os.Setenv("LEGO_HEALTH_ENABLE", "true"))
+	assert.Nil(t, os.Setenv("LEGO_SERVICE_URL", "http://127.0.0.1"))
+	defer os.Unsetenv("LEGO_HEALTH_ENABLE")
+	defer os.Unsetenv("LEGO_SERVICE_URL")
+
+	//文件中不存在的配置项
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, "[app]\nname = \"env\"\n"))
+	b := New(a)
+	var service struct {
+		Url string `mapstructure:"url" valid:"Required"`
+	}
+	b.BindConfig("service", &service)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+	assert.Equal(t, "http://127.0.0.1", service.Url)
+	h, _ := a.GetHealth()
+	assert.NotNil(t, h)
+}
+
+func TestBootstrap_Routes(t *testing.T) {
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, "[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = 0\n"))
+	b := New(a)
+	assert.Nil(t, b.Init())
+	assert.Nil(t, b.RegisterHttpRoutes(func(engine *gin.Engine) {
+		engine.POST("/b", func(c *gin.Context) {})
+		engine.GET("/a", func(c *gin.Context) {})
+	}))
+
+	buf := &bytes.Buffer{}
+	b.PrintRoutes(buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "GET   /a"))
+	assert.True(t, strings.HasPrefix(lines[1], "POST  /b"))
+	b.Shutdown()
+}
+
+func TestCheckConfig(t *testing.T) {
+	_, err := CheckConfig(writeConfig(t, "[log]\nlevel = \"info\"\n"), "develop")
+	assert.Nil(t, err)
+
+	_, err = CheckConfig(writeConfig(t, "[log]\nlevel = \"verbose\"\n"), "develop")
+	assert.Equal(t, "log.level", err.(config.BindErrors)[0].Key)
+}
+
+func TestBootstrap_PidFile(t *testing.T) {
+	pid := filepath.Join(t.TempDir(), "app.pid")
+	content := "[app]\nname = \"pid\"\npidfile = \"" + pid + "\"\n"
+
+	a1 := app.NewApplication()
+	a1.SetCfgFile(writeConfig(t, content))
+	b1 := New(a1)
+	assert.Nil(t, b1.Init())
+
+	//重复启动
+	a2 := app.NewApplication()
+	a2.SetCfgFile(writeConfig(t, content))
+	err := New(a2).Init()
+	assert.NotNil(t, err)
+	assert.Equal(t, "pid", err.(InitErrors)[0].Component)
+
+	b1.Shutdown()
+	_, err = os.Stat(pid)
+	assert.True(t, os.IsNotExist(err), "pid file need remove after shutdown")
+}
+
+func TestBootstrap_ShutdownReport(t *testing.T) {
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, "[app]\nname = \"shutdown\"\n[shutdown]\ntimeout = 5\n[shutdown.steps]\nslow = 1\n"))
+	b := New(a)
+	assert.Nil(t, b.Init())
+
+	var stopped int32
+	b.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		atomic.StoreInt32(&stopped, 1)
+	})
+	assert.Nil(t, b.RegisterShutdownStep("slow", func() {
+		time.Sleep(3 * time.Second)
+	}))
+
+	t1 := time.Now()
+	b.Shutdown()
+	assert.True(t, time.Since(t1) < 2*time.Second, "slow step need bound by step timeout")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped))
+	assert.Equal(t, []string{"slow"}, b.ShutdownReport().Exceeded())
+	assert.NotNil(t, b.ShutdownError())
+}
+
+func TestBootstrap_ShutdownFlushLog(t *testing.T) {
+	dir := t.TempDir()
+	a := app.NewApplication()
+	a.SetCfgFile(writeConfig(t, "[app]\nname = \"flush\"\n[log]\npath = \""+dir+"\"\nfilename = \"app.log\"\nsplit = \".%Y%m%d\"\nformat = \"json\"\nasync = true\nasync_policy = \"drop_oldest\"\nasync_flush_interval = 60000\n"))
+	b := New(a)
+	assert.Nil(t, b.Init())
+	a.GetLogger("").Info("before shutdown")
+
+	b.Shutdown()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "before shutdown")
+	assert.Contains(t, string(data), "shutdown complete")
+}
+
+func TestBootstrap_Signal(t *testing.T) {
+	b := newTestBootstrap(t, "signal")
+	s := sig.New()
+	b.SetSignal(s)
+	assert.Nil(t, b.Init())
+	defer s.Stop()
+
+	reloaded := make(chan struct{}, 1)
+	b.OnReload(0, func(ctx context.Context) error {
+		reloaded <- struct{}{}
+		return nil
+	})
+	filename, _ := b.App.GetCfgFile()
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("[app]\nname = \"signal\"\nrequest_id = \"x\"\n"), 0644))
+	s.Inject(syscall.SIGUSR2)
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("SIGUSR2 need reload config")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Run()
+	}()
+	s.Inject(syscall.SIGTERM)
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGTERM need stop run")
+	}
+}
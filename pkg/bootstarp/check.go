@@ -0,0 +1,28 @@
+package bootstarp
+
+import (
+	"github.com/jeevi-cao/lego/components/config"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//按环境加载配置并校验内置组件配置, 不初始化组件
+//usage:
+//	c, err := CheckConfig("./configs/config.toml", "develop")
+//	if err != nil {
+//		//err 为 config.BindErrors 时包含所有不合法配置项的完整路径
+//	}
+func CheckConfig(filename string, env string) (*config.Config, error) {
+	c, err := config.NewConfigWithSetting(config.Setting{
+		Filename:  filename,
+		Env:       env,
+		EnvPrefix: app.App.GetEnvPrefix(),
+		EnvKeys:   builtinEnvKeys(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSettings(c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
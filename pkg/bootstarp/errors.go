@@ -0,0 +1,88 @@
+package bootstarp
+
+import (
+	"fmt"
+	"strings"
+)
+
+//组件初始化错误
+type InitError struct {
+	//组件名称 对应初始化步骤名称
+	Component string
+	//实例名称 单实例为空
+	Instance string
+	Err      error
+}
+
+func (e *InitError) Error() string {
+	if len(e.Instance) > 0 {
+		return fmt.Sprintf("component:%s instance:%s error:%s", e.Component, e.Instance, e.Err.Error())
+	}
+	return fmt.Sprintf("component:%s error:%s", e.Component, e.Err.Error())
+}
+
+//聚合所有失败的组件及实例
+type InitErrors []*InitError
+
+func (e InitErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("init failed: %s", strings.Join(msgs, "; "))
+}
+
+//添加组件实例错误
+func (e *InitErrors) Add(component string, instance string, err error) {
+	*e = append(*e, &InitError{Component: component, Instance: instance, Err: err})
+}
+
+//合并步骤返回的错误, 已是InitError的保留原组件及实例
+func (e *InitErrors) merge(component string, err error) {
+	switch v := err.(type) {
+	case InitErrors:
+		*e = append(*e, v...)
+	case *InitError:
+		*e = append(*e, v)
+	default:
+		e.Add(component, "", err)
+	}
+}
+
+//没有错误时返回nil
+func (e InitErrors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+//聚合所有关闭失败的步骤
+type ShutdownErrors []*InitError
+
+func (e ShutdownErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("shutdown failed: %s", strings.Join(msgs, "; "))
+}
+
+//合并错误, 已是ShutdownErrors的展开
+func (e *ShutdownErrors) merge(component string, err error) {
+	switch v := err.(type) {
+	case nil:
+	case ShutdownErrors:
+		*e = append(*e, v...)
+	default:
+		*e = append(*e, &InitError{Component: component, Err: err})
+	}
+}
+
+//没有错误时返回nil
+func (e ShutdownErrors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
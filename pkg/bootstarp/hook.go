@@ -0,0 +1,149 @@
+package bootstarp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//生命周期事件
+//usage:
+//
+//	//http 已开始监听后注册服务发现, 失败时启动失败
+//	AfterStart(0, func(ctx context.Context) error {
+//		return registry.Register(ctx)
+//	})
+//	//关闭监听前先摘除
+//	BeforeStop(0, func(ctx context.Context) error {
+//		return registry.Deregister(ctx)
+//	})
+//
+//	priority 小的先执行, 相同时按注册顺序
+//	BeforeStart AfterStart 失败时中止启动, 其余事件的失败只记录 不影响后续函数执行
+type Event string
+
+const (
+	//启动监听及定时任务之前
+	EventBeforeStart Event = "before_start"
+	//http 已监听, 定时任务及自定义组件已启动, 标记就绪之前
+	EventAfterStart Event = "after_start"
+	//标记未就绪之后, 关闭组件之前
+	EventBeforeStop Event = "before_stop"
+	//所有组件关闭之后
+	EventAfterStop Event = "after_stop"
+	//配置变化并生效之后
+	EventReload Event = "reload"
+)
+
+type HookFunc func(ctx context.Context) error
+
+type hook struct {
+	priority int
+	f        HookFunc
+}
+
+//事件函数
+type hooks struct {
+	list  map[Event][]*hook
+	mutex sync.Mutex
+}
+
+func (h *hooks) add(event Event, priority int, f HookFunc) {
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	if h.list == nil {
+		h.list = make(map[Event][]*hook)
+	}
+	list := append(h.list[event], &hook{priority: priority, f: f})
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].priority < list[j].priority
+	})
+	h.list[event] = list
+}
+
+func (h *hooks) get(event Event) []*hook {
+	defer h.mutex.Unlock()
+	h.mutex.Lock()
+	return append([]*hook{}, h.list[event]...)
+}
+
+//执行事件函数, panic 转换为错误
+func (h *hook) run(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+	return h.f(ctx)
+}
+
+//按优先级执行事件函数
+//启动事件遇到错误立即返回, 其余事件执行全部函数后返回所有错误
+func (b *Bootstrap) runHooks(ctx context.Context, event Event) error {
+	abort := event == EventBeforeStart || event == EventAfterStart
+	msgs := make([]string, 0)
+	for _, h := range b.hooks.get(event) {
+		if err := h.run(ctx); err != nil {
+			if abort {
+				return errors.New(fmt.Sprintf("%s hook error:%s", event, err.Error()))
+			}
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(fmt.Sprintf("%s hook error:%s", event, strings.Join(msgs, "; ")))
+	}
+	return nil
+}
+
+//注册事件函数
+func (b *Bootstrap) AddHook(event Event, priority int, f HookFunc) {
+	b.hooks.add(event, priority, f)
+}
+
+func (b *Bootstrap) BeforeStart(priority int, f HookFunc) {
+	b.AddHook(EventBeforeStart, priority, f)
+}
+
+func (b *Bootstrap) AfterStart(priority int, f HookFunc) {
+	b.AddHook(EventAfterStart, priority, f)
+}
+
+func (b *Bootstrap) BeforeStop(priority int, f HookFunc) {
+	b.AddHook(EventBeforeStop, priority, f)
+}
+
+func (b *Bootstrap) AfterStop(priority int, f HookFunc) {
+	b.AddHook(EventAfterStop, priority, f)
+}
+
+func (b *Bootstrap) OnReload(priority int, f HookFunc) {
+	b.AddHook(EventReload, priority, f)
+}
+
+func AddHook(event Event, priority int, f HookFunc) {
+	std.AddHook(event, priority, f)
+}
+
+func BeforeStart(priority int, f HookFunc) {
+	std.BeforeStart(priority, f)
+}
+
+func AfterStart(priority int, f HookFunc) {
+	std.AfterStart(priority, f)
+}
+
+func BeforeStop(priority int, f HookFunc) {
+	std.BeforeStop(priority, f)
+}
+
+func AfterStop(priority int, f HookFunc) {
+	std.AfterStop(priority, f)
+}
+
+func OnReload(priority int, f HookFunc) {
+	std.OnReload(priority, f)
+}
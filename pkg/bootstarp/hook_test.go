@@ -0,0 +1,98 @@
+package bootstarp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+func TestBootstrap_Hooks(t *testing.T) {
+	a := app.NewApplication()
+	filename := writeConfig(t, "[app]\nname = \"hook\"\n[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = 0\n[health]\nenable = true\n")
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+
+	var mutex sync.Mutex
+	order := make([]string, 0)
+	record := func(name string) HookFunc {
+		return func(ctx context.Context) error {
+			mutex.Lock()
+			order = append(order, name)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	b.BeforeStart(10, record("before_start_10"))
+	b.BeforeStart(-1, record("before_start_-1"))
+	b.BeforeStart(10, record("before_start_10_2"))
+	//http 已监听
+	b.AfterStart(0, func(ctx context.Context) error {
+		hs, _ := a.GetHttpServer()
+		conn, err := net.Dial("tcp", hs.Listener.Addr().String())
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	b.AfterStart(1, record("after_start"))
+	//已标记未就绪, http 仍在监听
+	b.BeforeStop(0, func(ctx context.Context) error {
+		h, _ := a.GetHealth()
+		if h.IsReady() {
+			return errors.New("still ready in before stop")
+		}
+		hs, _ := a.GetHttpServer()
+		conn, err := net.Dial("tcp", hs.Listener.Addr().String())
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	b.BeforeStop(1, record("before_stop"))
+	b.AfterStop(0, record("after_stop"))
+	b.OnReload(0, record("reload"))
+
+	assert.Nil(t, b.Start())
+	assert.Equal(t, []string{"before_start_-1", "before_start_10", "before_start_10_2", "after_start"}, order)
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte("[app]\nname = \"hook\"\nrequest_id = \"x\"\n[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = 0\n[health]\nenable = true\n"), 0644))
+	assert.Nil(t, ReloadConfig(a))
+
+	b.Shutdown()
+	assert.Nil(t, b.ShutdownError())
+	assert.Equal(t, []string{"reload", "before_stop", "after_stop"}, order[4:])
+}
+
+func TestBootstrap_HookAbort(t *testing.T) {
+	b := newTestBootstrap(t, "hook")
+	assert.Nil(t, b.Init())
+
+	var called bool
+	b.BeforeStart(0, func(ctx context.Context) error {
+		return errors.New("registry unavailable")
+	})
+	b.AfterStart(0, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	b.AfterStop(0, func(ctx context.Context) error {
+		panic("boom")
+	})
+	err := b.Run()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "registry unavailable")
+	assert.False(t, called, "after start need skip when before start failed")
+
+	b.Shutdown()
+	errs, ok := b.ShutdownError().(ShutdownErrors)
+	assert.True(t, ok)
+	assert.Equal(t, string(EventAfterStop), errs[0].Component)
+}
@@ -1,58 +1,179 @@
 package bootstarp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
-	"strconv"
+	"path"
+	"path/filepath"
+	"reflect"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 
 	"github.com/jeevi-cao/lego/components/config"
 	"github.com/jeevi-cao/lego/components/crontab"
+	"github.com/jeevi-cao/lego/components/graceful"
+	"github.com/jeevi-cao/lego/components/health"
 	"github.com/jeevi-cao/lego/components/httpserver"
 	"github.com/jeevi-cao/lego/components/httpserver/middleware"
 	"github.com/jeevi-cao/lego/components/log"
 	"github.com/jeevi-cao/lego/components/mongo"
+	"github.com/jeevi-cao/lego/components/pidfile"
 	sig "github.com/jeevi-cao/lego/components/signal"
 	"github.com/jeevi-cao/lego/components/zookeeper"
 	"github.com/jeevi-cao/lego/pkg/app"
+	"github.com/jeevi-cao/lego/util"
 )
 
-var initFunc = []func(){
-	InitConfig,
-	InitLog,
-	InitApp,
-	InitPid,
-	InitCrontab,
-	InitHttpServer,
-	InitMongo,
-	InitZookeeper,
+//内置初始化步骤, 所有组件在配置校验通过后初始化
+func newInitSteps(b *Bootstrap) *steps {
+	return newSteps(
+		&Step{Name: "config", Fn: b.initConfig},
+		&Step{Name: "zookeeper", Deps: []string{"config"}, Fn: skipInRoutesMode(InitZookeeper)},
+		&Step{Name: "remote_config", Deps: []string{"config", "zookeeper"}, Fn: skipInRoutesMode(InitRemoteConfig)},
+		&Step{Name: "bind", Deps: []string{"config", "remote_config"}, Fn: b.initBind},
+		&Step{Name: "log", Deps: []string{"config", "bind"}, Fn: InitLog},
+		&Step{Name: "app", Deps: []string{"config", "log"}, Fn: InitApp},
+		&Step{Name: "pid", Deps: []string{"app"}, Fn: skipInRoutesMode(InitPid)},
+		&Step{Name: "crontab", Deps: []string{"log"}, Fn: InitCrontab},
+		&Step{Name: "httpserver", Deps: []string{"app"}, Fn: InitHttpServer},
+		&Step{Name: "mongo", Deps: []string{"log"}, Fn: skipInRoutesMode(InitMongo)},
+		&Step{Name: "components", Deps: []string{"log"}, Fn: skipInRoutesMode(InitComponents)},
+		&Step{Name: "health", Deps: []string{"httpserver", "crontab", "mongo", "zookeeper", "components"}, Fn: InitHealth},
+		&Step{Name: "admin", Deps: []string{"httpserver", "log"}, Fn: InitAdmin},
+	)
 }
 
-func Init() error {
+//按依赖顺序初始化, 互不依赖的步骤并行执行
+//任一必需步骤失败时返回InitErrors, 包含所有失败的组件及实例
+func (b *Bootstrap) Init() error {
+	defer b.lifecycle.Unlock()
+	b.lifecycle.Lock()
 	t1 := time.Now()
-	for _, f := range initFunc {
-		f()
+	if err := b.initSteps.runParallel(b.App); err != nil {
+		return err
+	}
+	watchRestartKeys(b.App)
+	//配置变化 组件订阅处理完成后执行 OnReload 事件函数
+	if c, err := b.App.GetConfig(); err == nil {
+		c.OnChange("", func(old, new map[string]interface{}) {
+			if err := b.runHooks(b.backgroundCtx, EventReload); err != nil {
+				b.App.GetLogger("").Errorf("[reload] %s", err.Error())
+			}
+		})
 	}
 
-	//注册信号函数
-	sig.WatchSignal(Shutdown, nil, nil)
-
+	b.watchSignal()
 	cost := time.Since(t1)
-	app.App.GetLogger("").Info("app init complete! time timeline:", cost)
+	b.App.GetLogger("").Info("app init complete! time timeline:", cost)
+	return nil
+}
+
+//注册信号处理函数并开始监听, 重复初始化时替换之前注册的函数
+//SIGINT SIGTERM SIGHUP 关闭并通知 Run 返回, 关闭中再次收到 SIGINT SIGTERM 强制退出
+//SIGUSR1 平滑重启
+//SIGUSR2 重新加载配置
+func (b *Bootstrap) watchSignal() {
+	if b.signal == nil {
+		return
+	}
+	for _, unregister := range b.unregisters {
+		unregister()
+	}
+	stop := func(ctx context.Context, s os.Signal) error {
+		b.App.GetLogger("").Infof("[signal] receive %s, shutdown", s)
+		b.Stop(true)
+		return nil
+	}
+	b.unregisters = []func(){
+		b.signal.Handle(syscall.SIGINT, stop),
+		b.signal.Handle(syscall.SIGTERM, stop),
+		b.signal.Handle(syscall.SIGHUP, stop),
+		b.signal.Handle(syscall.SIGUSR1, func(ctx context.Context, s os.Signal) error {
+			b.Restart()
+			return nil
+		}),
+		b.signal.Handle(syscall.SIGUSR2, func(ctx context.Context, s os.Signal) error {
+			return ReloadConfig(b.App)
+		}),
+	}
+	b.signal.OnError(func(s os.Signal, err error) {
+		b.App.GetLogger("").Errorf("[signal] %s error:%s", s, err.Error())
+	})
+	b.signal.Start()
+}
+
+//注册初始化函数, 在当前已注册的所有步骤之后执行
+func (b *Bootstrap) RegisterInit(f func() error) {
+	deps := b.initSteps.names()
+	_ = b.initSteps.add(&Step{Name: fmt.Sprintf("init_%d", len(deps)), Deps: deps, Fn: wrapStepFunc(f)})
+}
+
+//注册带依赖的初始化步骤
+//usage:
+//	RegisterInitStep("dao", initDao, "mongo", "log")
+func (b *Bootstrap) RegisterInitStep(name string, f func() error, deps ...string) error {
+	return b.initSteps.add(&Step{Name: name, Deps: deps, Fn: wrapStepFunc(f)})
+}
+
+//标记初始化步骤为可选, 失败时只记录警告 不影响启动
+//usage:
+//	SetOptional("zookeeper")
+func (b *Bootstrap) SetOptional(name string) error {
+	return b.initSteps.setOptional(name)
+}
+
+//注册配置绑定, 初始化时在所有组件之前解析并校验, 失败时报告全部不合法的配置项
+//usage:
+//	var setting struct {
+//		Url string `mapstructure:"url" valid:"Required"`
+//	}
+//	BindConfig("service.user", &setting)
+func (b *Bootstrap) BindConfig(key string, out interface{}) {
+	b.bindings = append(b.bindings, &binding{key: key, out: out})
+}
+
+//校验内置组件配置 并解析所有注册的配置绑定
+//重新加载配置时同样校验, 不合法的配置不会生效
+func (b *Bootstrap) initBind(a *app.Application) error {
+	c, _ := a.GetConfig()
+	if err := b.validateConfig(c); err != nil {
+		return err
+	}
+	for _, bd := range b.bindings {
+		if err := c.Bind(bd.key, bd.out); err != nil {
+			return err
+		}
+	}
+	c.AddValidator(b.validateConfig)
 	return nil
 }
 
-func RegisterInit(f func()) {
-	initFunc = append(initFunc, f)
+//校验内置组件配置与注册的配置绑定, 返回全部不合法的配置项
+//绑定到新的实例校验, 不修改注册的结构体
+func (b *Bootstrap) validateConfig(c *config.Config) error {
+	errs := config.BindErrors{}
+	if err := validateSettings(c); err != nil {
+		errs = append(errs, err.(config.BindErrors)...)
+	}
+	for _, bd := range b.bindings {
+		out := reflect.New(reflect.TypeOf(bd.out).Elem()).Interface()
+		if err := c.Bind(bd.key, out); err != nil {
+			errs = append(errs, err.(config.BindErrors)...)
+		}
+	}
+	return errs.ErrorOrNil()
 }
 
 //注册route
-func RegisterHttpRoutes(f func(engine *gin.Engine)) error {
-	hs, _ := app.App.GetHttpServer()
+func (b *Bootstrap) RegisterHttpRoutes(f func(engine *gin.Engine)) error {
+	hs, _ := b.App.GetHttpServer()
 	if hs == nil {
 		return errors.New("http server not init")
 	}
@@ -61,8 +182,8 @@ func RegisterHttpRoutes(f func(engine *gin.Engine)) error {
 }
 
 //注册定时任务
-func RegisterCrontabTask(callbacks ...func(scheduler crontab.Scheduler)) error {
-	cron, _ := app.App.GetCrontab()
+func (b *Bootstrap) RegisterCrontabTask(callbacks ...func(scheduler crontab.Scheduler)) error {
+	cron, _ := b.App.GetCrontab()
 	if cron == nil {
 		return errors.New("crontab not init")
 	}
@@ -70,270 +191,491 @@ func RegisterCrontabTask(callbacks ...func(scheduler crontab.Scheduler)) error {
 	return nil
 }
 
-//注册信号监听函数
-func RegisterSignalFunc(f sig.CallbackSignal) {
-	sig.AddWatchFunc(f)
+//注册健康检查
+func (b *Bootstrap) RegisterHealthCheck(name string, f health.CheckFunc) error {
+	h, _ := b.App.GetHealth()
+	if h == nil {
+		return errors.New("health not init")
+	}
+	h.Register(name, f)
+	return nil
+}
+
+func Init() error {
+	return std.Init()
+}
+
+func RegisterInit(f func() error) {
+	std.RegisterInit(f)
+}
+
+func RegisterInitStep(name string, f func() error, deps ...string) error {
+	return std.RegisterInitStep(name, f, deps...)
+}
+
+func SetOptional(name string) error {
+	return std.SetOptional(name)
+}
+
+func BindConfig(key string, out interface{}) {
+	std.BindConfig(key, out)
+}
+
+func RegisterHttpRoutes(f func(engine *gin.Engine)) error {
+	return std.RegisterHttpRoutes(f)
+}
+
+func RegisterCrontabTask(callbacks ...func(scheduler crontab.Scheduler)) error {
+	return std.RegisterCrontabTask(callbacks...)
+}
+
+func RegisterHealthCheck(name string, f health.CheckFunc) error {
+	return std.RegisterHealthCheck(name, f)
+}
+
+//注册信号处理函数, 返回取消注册的函数
+func RegisterSignalFunc(s os.Signal, f sig.Handler) func() {
+	return sig.Handle(s, f)
 }
 
 //初始化配置
-func InitConfig() {
-	cfg, err := app.App.GetCfgFile()
+func InitConfig(a *app.Application) error {
+	return initConfig(a, builtinEnvKeys())
+}
+
+//内置组件及注册的配置绑定均可通过环境变量设置
+func (b *Bootstrap) initConfig(a *app.Application) error {
+	keys := builtinEnvKeys()
+	for _, bd := range b.bindings {
+		keys = append(keys, config.StructKeys(bd.key, bd.out)...)
+	}
+	return initConfig(a, keys)
+}
+
+func initConfig(a *app.Application, envKeys []string) error {
+	cfg, err := a.GetCfgFile()
 	if err != nil {
-		panic(err.Error())
+		return err
 	}
 
-	c, err := config.NewConfig(cfg)
+	//基础文件 -> 环境覆盖文件 -> 环境变量
+	c, err := config.NewConfigWithSetting(config.Setting{
+		Filename:  cfg,
+		Env:       a.GetEnvName(),
+		EnvPrefix: a.GetEnvPrefix(),
+		EnvKeys:   envKeys,
+	})
 	if err != nil {
-		panic(fmt.Sprintf("[init] config error:%s", err.Error()))
+		return err
 	}
-	//这是自动热加载文件
-	c.WatchReConfig()
-	app.App.SetConfig(c)
+	//这是自动热加载文件, 加载失败时保留原配置
+	c.OnError(func(err error) {
+		a.GetLogger("").Errorf("[reload] config error:%s", err.Error())
+	})
+	//记录变更, 敏感配置项隐藏
+	c.OnChange("", func(old, new map[string]interface{}) {
+		a.GetLogger("").Infof("[reload] config changed old:%v new:%v", c.RedactValues(old), c.RedactValues(new))
+	})
+	if err := c.WatchReConfig(); err != nil {
+		a.GetLogger("").Warnf("[init] config watch error:%s", err.Error())
+	}
+	a.SetConfig(c)
+	return nil
 }
 
 //初始化日志 -- 核心加载
 //TODO 是否可以改成懒加载
-func InitLog() {
-	cfg := app.App.GetConfiger()
-	//多实例
-	if cfg.IsSet("log.type") && app.IsMultiInstance(cfg.GetString("log.type")) {
-		instances := cfg.GetStringMap("log.instance")
-		for instance := range instances {
-			prefix := "log.instance." + instance + "."
-			setting := log.Setting{
-				Path:            cfg.GetString(prefix + "path"),
-				FileName:        cfg.GetString(prefix + "filename"),
-				ErrFileName:     cfg.GetString(prefix + "errfilename"),
-				Level:           cfg.GetString(prefix + "level"),
-				Format:          cfg.GetString(prefix + "format"),
-				Split:           cfg.GetString(prefix + "split"),
-				LifeTime:        cfg.GetDuration(prefix + "lifetime"),
-				Rotation:        cfg.GetDuration(prefix + "rotation"),
-				ReportCaller:    true,
-				ReportHostIp:    true,
-				ReportShortFile: true,
-			}
-			l, err := log.NewLog(setting)
-			if err != nil {
-				panic(fmt.Sprintf("[init] log instance: %s error:%s", instance, err.Error()))
-			}
-			app.App.SetLog(instance, l)
-		}
-	} else {
-		setting := log.Setting{
-			Path:            cfg.GetString("log.path"),
-			FileName:        cfg.GetString("log.filename"),
-			ErrFileName:     cfg.GetString("log.errfilename"),
-			Level:           cfg.GetString("log.level"),
-			Format:          cfg.GetString("log.format"),
-			Split:           cfg.GetString("log.split"),
-			LifeTime:        cfg.GetDuration("log.lifetime"),
-			Rotation:        cfg.GetDuration("log.rotation"),
-			ReportCaller:    true,
-			ReportHostIp:    true,
-			ReportShortFile: true,
-		}
-		l, err := log.NewLog(setting)
+func InitLog(a *app.Application) error {
+	c, _ := a.GetConfig()
+	instances, err := bindLog(c)
+	if err != nil {
+		return err
+	}
+	errs := InitErrors{}
+	for instance, s := range instances {
+		l, err := log.NewLog(newLogSetting(s))
 		if err != nil {
-			panic(fmt.Sprintf("[init] log error:%s", err.Error()))
+			errs.Add("log", instance, err)
+			continue
 		}
-		app.App.SetLog("", l)
+		a.SetLog(instance, l)
 	}
-	app.App.GetLogger("").Info("[init] log component complete !")
+	if len(errs) > 0 {
+		return errs
+	}
+	//日志文件及级别热更新
+	c.OnChange("log", func(old, new map[string]interface{}) {
+		if err := ReloadLog(a); err != nil {
+			a.GetLogger("").Errorf("[reload] %s", err.Error())
+		}
+	})
+	a.GetLogger("").Info("[init] log component complete !")
+	return nil
 }
 
 //初始化app
-func InitApp() {
-	cfg := app.App.GetConfiger()
-	name := cfg.GetString("app.name")
-	app.App.SetName(name)
-
-	if cfg.IsSet("app.request_id") {
-		app.App.SetRequestId(cfg.GetString("app.request_id"))
+func InitApp(a *app.Application) error {
+	c, _ := a.GetConfig()
+	s := &appSetting{}
+	if err := c.Bind("app", s); err != nil {
+		return err
+	}
+	a.SetName(s.Name)
+	if len(s.RequestId) > 0 {
+		a.SetRequestId(s.RequestId)
 	}
 
-	app.App.GetLogger("").Info("[init] app component complete !")
+	a.GetLogger("").Info("[init] app component complete !")
+	return nil
 }
 
-//pid设置
-func InitPid() {
-	pid := os.Getpid()
-	pidfile := app.App.GetConfiger().GetString("app.pidfile")
-	if len(pidfile) < 1 {
-		app.App.GetLogger("").Infof("[init] not need init pid file")
-		return
+//pid设置, 加锁防止重复启动, 替换进程已不存在的过期pid文件
+func InitPid(a *app.Application) error {
+	c, _ := a.GetConfig()
+	s := &appSetting{}
+	if err := c.Bind("app", s); err != nil {
+		return err
 	}
-	//判断当前pid 是否存储
-	file, err := os.OpenFile(pidfile, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		app.App.GetLogger("").Warnf("[init] create pid file error:%s", err.Error())
-		return
+	filename := s.Pidfile
+	if len(filename) < 1 {
+		a.GetLogger("").Infof("[init] not need init pid file")
+		return nil
+	}
+	p := pidfile.New(filename)
+	//平滑重启 继承父进程的锁
+	if lock := graceful.File("pid"); lock != nil {
+		if err := p.Inherit(lock); err != nil {
+			return err
+		}
+		a.GetLogger("").Infof("[init] inherit pid file:%s from pid:%d", filename, p.StalePid())
+	} else if err := p.Lock(); err != nil {
+		return err
+	} else if stale := p.StalePid(); stale > 0 {
+		a.GetLogger("").Warnf("[init] replace stale pid file:%s pid:%d", filename, stale)
 	}
-	_, _ = file.WriteString(strconv.Itoa(pid))
-	_ = file.Close()
+	a.SetPidFile(p)
 
-	app.App.GetLogger("").Infof("[init] create pid file pid:%d", pid)
+	a.GetLogger("").Infof("[init] create pid file pid:%d", p.Pid())
+	return nil
 }
 
 //定时任务初始化
-func InitCrontab() {
-	cfg := app.App.GetConfiger()
-	enable := cfg.GetBool("crontab.enable")
-	if enable {
-		app.App.SetCrontab(crontab.New())
-		app.App.GetLogger("").Infof("[init] crontab component complete!")
+func InitCrontab(a *app.Application) error {
+	c, _ := a.GetConfig()
+	s := &crontabSetting{}
+	if err := c.Bind("crontab", s); err != nil {
+		return err
+	}
+	if s.Enable {
+		a.SetCrontab(crontab.New())
+		a.GetLogger("").Infof("[init] crontab component complete!")
 	}
+	return nil
 }
 
 //初始化server
-func InitHttpServer() {
-	cfg := app.App.GetConfiger()
-	if !cfg.IsSet("httpserver.http_host") {
-		return
+func InitHttpServer(a *app.Application) error {
+	c, _ := a.GetConfig()
+	if !c.GetHandler().IsSet("httpserver.http_host") {
+		return nil
+	}
+	s := &httpServerSetting{}
+	if err := c.Bind("httpserver", s); err != nil {
+		return err
 	}
-	host := cfg.GetString("httpserver.http_host")
-	port := cfg.GetInt("httpserver.http_port")
-	isHttps := cfg.GetBool("httpserver.enable_https")
-	middlewares := cfg.GetStringSlice("httpserver.middleware")
 
 	//日志输出, 测试环境 双写
-	l, _ := app.App.GetLog("")
+	l, _ := a.GetLog("")
 	outWriter := l.Writer
-	if app.App.IsDevelop() {
+	if a.IsDevelop() {
 		outWriter = io.MultiWriter(os.Stdout, outWriter)
 	}
-	//改写gin日志数据地址
-	gin.DefaultErrorWriter = outWriter
-	gin.DefaultWriter = outWriter
-
-	hs := httpserver.NewHttpServer(host, port, isHttps)
+	//gin 日志输出按服务设置, 不修改 gin 全局输出
+	hs := httpserver.NewHttpServer(s.HttpHost, s.HttpPort, s.EnableHttps)
+	hs.SetOutput(outWriter)
+	//平滑重启 继承父进程的监听, 端口变化时重新监听
+	listener, err := graceful.Listener("httpserver")
+	if err != nil {
+		return err
+	}
+	if listener != nil {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok && addr.Port == s.HttpPort {
+			hs.SetListener(listener)
+			a.GetLogger("").Infof("[init] http server inherit listener:%s", addr)
+		} else {
+			_ = listener.Close()
+		}
+	}
 
 	//非测试环境 打开
-	if !app.App.IsDevelop() {
+	if !a.IsDevelop() {
 		hs.SetServerModeRelease()
 	}
 
-	//TODO 这段代码逻辑不太好
-	if len(middlewares) > 0 {
-		for _, mw := range middlewares {
-			switch mw {
-			case "cors":
-				hs.SetMiddleware(middleware.CorsMiddleWare())
-			case "requestid":
-				hs.SetMiddleware(middleware.RequestIdMiddleware(app.App.GetRequestId()))
-			case "ydlogger":
-				hs.SetMiddleware(middleware.YdLoggerMiddleWare(outWriter))
-			}
+	//内置中间件全部挂载, 按配置开启, 配置变化时热更新
+	//执行顺序: 初始配置的顺序, 其余按 cors requestid ydlogger
+	middlewares := map[string]gin.HandlerFunc{
+		"cors":      middleware.CorsMiddleWare(),
+		"requestid": middleware.RequestIdMiddleware(a.GetRequestId()),
+		"ydlogger":  middleware.YdLoggerMiddleWare(outWriter),
+	}
+	names := append([]string{}, s.Middleware...)
+	names = append(names, "cors", "requestid", "ydlogger")
+	for _, name := range names {
+		if mw, ok := middlewares[name]; ok {
+			enable, _ := util.Contain(name, s.Middleware)
+			hs.SetSwitchMiddleware(name, enable, mw)
+			delete(middlewares, name)
 		}
 	}
-	app.App.SetHttpServer(hs)
-	app.App.GetLogger("").Info("[init] http server complete!")
+	c.OnChange("httpserver.middleware", func(old, new map[string]interface{}) {
+		s := &httpServerSetting{}
+		if err := c.Bind("httpserver", s); err != nil {
+			a.GetLogger("").Errorf("[reload] httpserver error:%s", err.Error())
+			return
+		}
+		hs.EnableMiddleware(s.Middleware...)
+		a.GetLogger("").Infof("[reload] httpserver middleware:%v", hs.GetEnableMiddleware())
+	})
+	a.SetHttpServer(hs)
+	a.GetLogger("").Info("[init] http server complete!")
+	return nil
 }
 
 //初始化mongo
-func InitMongo() {
-	cfg := app.App.GetConfiger()
+func InitMongo(a *app.Application) error {
+	c, _ := a.GetConfig()
+	//连接配置变化的实例重新连接
+	c.OnChange("mongo", func(old, new map[string]interface{}) {
+		if err := ReloadMongo(a); err != nil {
+			a.GetLogger("").Errorf("[reload] %s", err.Error())
+		}
+	})
 
 	//判断是否有配置
-	if !cfg.IsSet("mongo") {
-		return
+	if !c.GetHandler().IsSet("mongo") {
+		return nil
 	}
-	var instances map[string]interface{}
-	var prefix string
-	var multi bool
-	//判断是否多实例
-	if cfg.IsSet("mongo.type") && app.IsMultiInstance(cfg.GetString("mongo.type")) {
-		instances = cfg.GetStringMap("mongo.instance")
-		prefix = "mongo.instance."
-		multi = true
-	} else {
-		instances = map[string]interface{}{"mongo": ""}
-		prefix = ""
-		multi = false
-	}
-	for instance := range instances {
-		pre := prefix + instance + "."
-		setting := &mongo.Setting{}
-		if cfg.IsSet(pre + "uri") {
-			setting.Uri = cfg.GetString(pre + "uri")
-		}
-		if cfg.IsSet(pre + "hosts") {
-			setting.Hosts = cfg.GetString(pre + "hosts")
-		}
-		if cfg.IsSet(pre + "replset") {
-			setting.ReplSet = cfg.GetString(pre + "replset")
-		}
-		if cfg.IsSet(prefix + "username") {
-			setting.Username = cfg.GetString(pre + "username")
-		}
-		if cfg.IsSet(prefix + "password") {
-			setting.Password = cfg.GetString(pre + "password")
-		}
-		if cfg.IsSet(prefix + "max_pool_size") {
-			setting.MaxPoolSize = cfg.GetUint64(pre + "max_pool_size")
-		}
-		if cfg.IsSet(prefix + "min_pool_size") {
-			setting.MinPoolSize = cfg.GetUint64(pre + "min_pool_size")
-		}
-		if cfg.IsSet(prefix + "max_idle_time") {
-			setting.MaxIdleTime = cfg.GetInt(prefix + "max_idle_time")
+	s, instances, err := bindMongo(c)
+	if err != nil {
+		return err
+	}
+	errs := InitErrors{}
+	for instance, is := range instances {
+		mg, err := mongo.NewMongo(newMongoSetting(is))
+		if err != nil {
+			if s.Optional || is.Optional {
+				a.GetLogger("").Warnf("[init] optional mongo instance:%s degraded error:%s", instance, err.Error())
+				continue
+			}
+			errs.Add("mongo", instance, err)
+			continue
 		}
-		if cfg.IsSet(prefix + "read_preference") {
-			setting.ReadPreference = cfg.GetString(pre + "read_preference")
+		a.SetMongo(instance, mg)
+		a.GetLogger("").Infof("[init] mongo instance:%s set !", instance)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	a.GetLogger("").Info("[init] mongo component complete !")
+	return nil
+}
+
+func InitZookeeper(a *app.Application) error {
+	c, _ := a.GetConfig()
+	if !c.GetHandler().IsSet("zookeeper") {
+		return nil
+	}
+	s, instances, err := bindZookeeper(c)
+	if err != nil {
+		return err
+	}
+	errs := InitErrors{}
+	for instance, is := range instances {
+		sessionTimeout := 5 * time.Second
+		if is.SessionTimeout > 0 {
+			sessionTimeout = time.Duration(is.SessionTimeout) * time.Second
 		}
-		mg, err := mongo.NewMongo(setting)
+		zb, err := zookeeper.NewZkBuilder(is.Hosts, sessionTimeout)
 		if err != nil {
-			app.App.GetLogger("").Fatalf("[init] mongo instance:%s  error:%s", instance, err.Error())
+			if s.Optional || is.Optional {
+				a.GetLogger("").Warnf("[init] optional zookeeper instance:%s degraded error:%s", instance, err.Error())
+				continue
+			}
+			errs.Add("zookeeper", instance, err)
 			continue
 		}
-		if !multi {
-			instance = ""
-		}
-		app.App.SetMongo(instance, mg)
-		app.App.GetLogger("").Infof("[init] mongo instance:%s set !", instance)
+		a.SetZookeeper(instance, zb)
+		a.GetLogger("").Infof("[init] zookeeper instance:%s set !", instance)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
-	app.App.GetLogger("").Info("[init] mongo component complete !")
+	a.GetLogger("").Info("[init] zookeeper component complete !")
+	return nil
 }
 
-func InitZookeeper() {
-	cfg := app.App.GetConfiger()
-	if !cfg.IsSet("zookeeper") {
-		return
+//初始化远程配置, 合并在本地配置之上, 在配置校验及其他组件之前加载
+//zookeeper 不可用时使用本地缓存
+//	[remote_config]
+//	enable = true
+//	zookeeper = ""  zookeeper实例名
+//	path = "/config"
+//	format = "toml"
+func InitRemoteConfig(a *app.Application) error {
+	c, _ := a.GetConfig()
+	if !c.GetHandler().GetBool("remote_config.enable") {
+		return nil
+	}
+	s := &remoteConfigSetting{}
+	if err := c.Bind("remote_config", s); err != nil {
+		return err
+	}
+	zb, err := a.GetZookeeper(s.Zookeeper)
+	if err != nil {
+		return errors.New(fmt.Sprintf("remote config need zookeeper instance:%s error:%s", s.Zookeeper, err.Error()))
+	}
+	_, instances, err := bindZookeeper(c)
+	if err != nil {
+		return err
+	}
+	basePath := ""
+	if is, ok := instances[s.Zookeeper]; ok {
+		basePath = is.BasePath
+	}
+	cache := s.Cache
+	if len(cache) == 0 {
+		cache = filepath.Join(filepath.Dir(c.Setting.Filename), "remote_config.cache.json")
 	}
-	//判断是否多实例
-	var instances map[string]interface{}
-	var prefix string
-	var multi bool
 
-	if cfg.IsSet("zookeeper.type") && app.IsMultiInstance(cfg.GetString("zookeeper.type")) {
-		instances = cfg.GetStringMap("zookeeper.instance")
-		prefix = "zookeeper.instance."
-		multi = true
-	} else {
-		instances = map[string]interface{}{"zookeeper": ""}
-		prefix = ""
-		multi = false
+	p := zookeeper.NewConfigProvider(zb, path.Join("/", basePath, s.Path), s.Format)
+	p.Timeout = 5 * time.Second
+	if s.Timeout > 0 {
+		p.Timeout = time.Duration(s.Timeout) * time.Second
+	}
+	if err := c.AddRemote(p, cache); err != nil {
+		return err
 	}
+	a.GetLogger("").Infof("[init] remote config path:%s complete !", p.Path)
+	return nil
+}
 
-	for instance := range instances {
-		pre := prefix + instance + "."
+//初始化自定义组件
+func InitComponents(a *app.Application) error {
+	cfg := a.GetConfiger()
+	errs := InitErrors{}
+	for _, name := range a.GetComponentNames() {
+		if !cfg.IsSet(name) {
+			continue
+		}
+		factory, _ := a.GetComponentFactory(name)
+		//判断是否多实例
+		var instances map[string]interface{}
+		var prefix string
+		var multi bool
 
-		hosts := cfg.GetStringSlice(pre + "hosts")
-		t := cfg.GetInt(pre + "session_timeout")
-		sessionTimeout := 5 * time.Second
-		if t > 0 {
-			sessionTimeout = time.Duration(t) * time.Second
+		if cfg.IsSet(name+".type") && app.IsMultiInstance(cfg.GetString(name+".type")) {
+			instances = cfg.GetStringMap(name + ".instance")
+			prefix = name + ".instance."
+			multi = true
+		} else {
+			instances = map[string]interface{}{name: ""}
+			prefix = ""
+			multi = false
 		}
-		zb, err := zookeeper.NewZkBuilder(hosts, sessionTimeout)
-		if err != nil {
-			app.App.GetLogger("").Fatalf("[init] zookeeper instance:%s error:%s", instance, err.Error())
-			continue
+
+		for instance := range instances {
+			c := factory()
+			if err := c.Init(cfg.Sub(prefix + instance)); err != nil {
+				if isOptional(cfg, name, prefix+instance+".") {
+					a.GetLogger("").Warnf("[init] optional %s instance:%s degraded error:%s", name, instance, err.Error())
+					continue
+				}
+				errs.Add(name, instance, err)
+				continue
+			}
+			if !multi {
+				instance = ""
+			}
+			a.SetComponent(name, instance, c)
+			a.GetLogger("").Infof("[init] %s instance:%s set !", name, instance)
 		}
-		if multi == false {
-			instance = ""
+		a.GetLogger("").Infof("[init] %s component complete !", name)
+	}
+	return errs.ErrorOrNil()
+}
+
+//初始化健康检查, 汇总各组件检查项并注册 /healthz /readyz
+func InitHealth(a *app.Application) error {
+	c, _ := a.GetConfig()
+	s := &healthSetting{}
+	if err := c.Bind("health", s); err != nil {
+		return err
+	}
+	if !s.Enable {
+		return nil
+	}
+	h := health.New(health.Setting{
+		Timeout:     time.Duration(s.Timeout) * time.Second,
+		CacheTTL:    time.Duration(s.CacheTtl) * time.Millisecond,
+		HealthzPath: s.HealthzPath,
+		ReadyzPath:  s.ReadyzPath,
+	})
+
+	mongos, _ := a.GetAllMongo()
+	for instance := range mongos {
+		registerMongoHealth(a, h, instance)
+	}
+	zookeepers, _ := a.GetAllZookeeper()
+	for instance, z := range zookeepers {
+		z := z
+		h.Register("zookeeper."+instance, func(ctx context.Context) error {
+			return z.Ping()
+		})
+	}
+	if cron, _ := a.GetCrontab(); cron != nil {
+		h.Register("crontab", func(ctx context.Context) error {
+			if !cron.IsRunning() {
+				return errors.New("crontab not running")
+			}
+			return nil
+		})
+	}
+	for _, name := range a.GetComponentNames() {
+		components, _ := a.GetAllComponent(name)
+		for instance, c := range components {
+			c := c
+			h.Register(name+"."+instance, func(ctx context.Context) error {
+				return c.Health()
+			})
 		}
-		app.App.SetZookeeper(instance, zb)
-		app.App.GetLogger("").Info("[init] mongo instance:%s set !", instance)
 	}
-	app.App.GetLogger("").Info("[init] zookeeper component complete !")
+	if hs, _ := a.GetHttpServer(); hs != nil {
+		h.Routes(hs.Engine)
+	}
+	a.SetHealth(h)
+	a.GetLogger("").Info("[init] health component complete !")
+	return nil
+}
+
+//注册mongo实例检查, 检查时获取当前实例, 重新加载替换后检查新实例
+func registerMongoHealth(a *app.Application, h *health.Health, instance string) {
+	h.Register("mongo."+instance, func(ctx context.Context) error {
+		mg, release, err := a.AcquireMongo(instance)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return mg.Ping(ctx)
+	})
+}
+
+//组件或实例是否标记为可选, 可选实例初始化失败时降级跳过
+//	[mongo]
+//	optional = true
+//或
+//	[mongo.instance.db1]
+//	optional = true
+func isOptional(cfg *viper.Viper, name string, pre string) bool {
+	return cfg.GetBool(name+".optional") || cfg.GetBool(pre+"optional")
 }
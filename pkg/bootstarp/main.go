@@ -0,0 +1,205 @@
+package bootstarp
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jeevi-cao/lego/components/crontab"
+)
+
+//标准入口
+//usage:
+//
+//	func main() {
+//		os.Exit(bootstarp.Main(
+//			bootstarp.WithRoutes(routes),
+//			bootstarp.WithCrontab(tasks),
+//			bootstarp.WithShutdown(closeDao),
+//		))
+//	}
+//
+//	./service -c ./configs/config.toml -e prod
+//	配置文件及环境读取顺序: -c -e 参数 -> LEGO_CONFIG LEGO_ENV 环境变量 -> 默认值
+//	环境变量前缀与 app.EnvPrefix 相同
+
+//退出码
+const (
+	ExitOK = 0
+	//初始化或注册失败
+	ExitInitFailed = 1
+	//参数错误
+	ExitUsage = 2
+	//关闭失败
+	ExitShutdownFailed = 3
+)
+
+//默认值
+const (
+	DefaultConfigFile = "./configs/config.toml"
+	DefaultEnv        = "develop"
+)
+
+type mainOptions struct {
+	args     []string
+	output   io.Writer
+	name     string
+	config   string
+	env      string
+	routes   []func(engine *gin.Engine)
+	crontabs []func(scheduler crontab.Scheduler)
+	inits    []func() error
+	shutdown []func()
+}
+
+type Option func(o *mainOptions)
+
+//命令行参数, 默认 os.Args[1:]
+func WithArgs(args []string) Option {
+	return func(o *mainOptions) {
+		o.args = args
+	}
+}
+
+//错误输出, 默认 os.Stderr
+func WithOutput(w io.Writer) Option {
+	return func(o *mainOptions) {
+		o.output = w
+	}
+}
+
+//应用名称, 覆盖 app.name 配置
+func WithName(name string) Option {
+	return func(o *mainOptions) {
+		o.name = name
+	}
+}
+
+//默认配置文件
+func WithConfig(filename string) Option {
+	return func(o *mainOptions) {
+		o.config = filename
+	}
+}
+
+//默认环境
+func WithEnv(env string) Option {
+	return func(o *mainOptions) {
+		o.env = env
+	}
+}
+
+//注册路由
+func WithRoutes(f ...func(engine *gin.Engine)) Option {
+	return func(o *mainOptions) {
+		o.routes = append(o.routes, f...)
+	}
+}
+
+//注册定时任务
+func WithCrontab(f ...func(scheduler crontab.Scheduler)) Option {
+	return func(o *mainOptions) {
+		o.crontabs = append(o.crontabs, f...)
+	}
+}
+
+//注册初始化函数, 在内置组件之后执行
+func WithInit(f ...func() error) Option {
+	return func(o *mainOptions) {
+		o.inits = append(o.inits, f...)
+	}
+}
+
+//注册关闭函数, 在内置组件之前执行
+func WithShutdown(f ...func()) Option {
+	return func(o *mainOptions) {
+		o.shutdown = append(o.shutdown, f...)
+	}
+}
+
+//解析参数 初始化 注册 运行, 收到停止信号后关闭并返回退出码
+func (b *Bootstrap) Main(opts ...Option) int {
+	o := &mainOptions{
+		args:   os.Args[1:],
+		output: os.Stderr,
+		config: DefaultConfigFile,
+		env:    DefaultEnv,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	//环境变量 -> 参数
+	prefix := b.App.GetEnvPrefix()
+	if v := os.Getenv(prefix + "_CONFIG"); len(v) > 0 {
+		o.config = v
+	}
+	if v := os.Getenv(prefix + "_ENV"); len(v) > 0 {
+		o.env = v
+	}
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(o.output)
+	fs.StringVar(&o.config, "c", o.config, "config file, env "+prefix+"_CONFIG")
+	fs.StringVar(&o.env, "e", o.env, "environment: develop test release prod, env "+prefix+"_ENV")
+	if err := fs.Parse(o.args); err != nil {
+		return ExitUsage
+	}
+	if err := b.App.SetEnv(o.env); err != nil {
+		fmt.Fprintln(o.output, err.Error())
+		return ExitUsage
+	}
+	b.App.SetCfgFile(o.config)
+
+	for _, f := range o.inits {
+		b.RegisterInit(f)
+	}
+	for _, f := range o.shutdown {
+		b.RegisterShutdown(f)
+	}
+	if err := b.Init(); err != nil {
+		fmt.Fprintln(o.output, err.Error())
+		b.Shutdown()
+		return ExitInitFailed
+	}
+	if len(o.name) > 0 {
+		b.App.SetName(o.name)
+	}
+	for _, f := range o.routes {
+		if err := b.RegisterHttpRoutes(f); err != nil {
+			fmt.Fprintln(o.output, err.Error())
+			b.Shutdown()
+			return ExitInitFailed
+		}
+	}
+	if len(o.crontabs) > 0 {
+		if err := b.RegisterCrontabTask(o.crontabs...); err != nil {
+			fmt.Fprintln(o.output, err.Error())
+			b.Shutdown()
+			return ExitInitFailed
+		}
+	}
+
+	//只输出路由
+	if isRoutesMode() {
+		b.PrintRoutes(os.Stdout)
+		b.Shutdown()
+		return ExitOK
+	}
+	if err := b.Run(); err != nil {
+		fmt.Fprintln(o.output, err.Error())
+		b.Shutdown()
+		return ExitInitFailed
+	}
+	if err := b.ShutdownError(); err != nil {
+		fmt.Fprintln(o.output, err.Error())
+		return ExitShutdownFailed
+	}
+	return ExitOK
+}
+
+func Main(opts ...Option) int {
+	return std.Main(opts...)
+}
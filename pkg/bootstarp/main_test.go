@@ -0,0 +1,159 @@
+package bootstarp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/components/httpserver"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//运行Main 就绪后停止
+func runMain(t *testing.T, b *Bootstrap, opts ...Option) int {
+	done := make(chan int, 1)
+	go func() {
+		done <- b.Main(opts...)
+	}()
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(100 * time.Millisecond):
+	}
+	b.Stop(true)
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(2 * time.Second):
+		t.Fatal("main not return after stop")
+	}
+	return -1
+}
+
+func TestBootstrap_Main(t *testing.T) {
+	filename := writeConfig(t, "[app]\nname = \"main\"\n[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = 0\n")
+	out := &bytes.Buffer{}
+
+	//参数错误
+	assert.Equal(t, ExitUsage, New(app.NewApplication()).Main(WithArgs([]string{"-x"}), WithOutput(out)))
+	assert.Equal(t, ExitUsage, New(app.NewApplication()).Main(WithArgs([]string{"-e", "none"}), WithOutput(out)))
+	//初始化失败
+	missing := filepath.Join(t.TempDir(), "none.toml")
+	assert.Equal(t, ExitInitFailed, New(app.NewApplication()).Main(WithArgs([]string{"-c", missing}), WithOutput(out)))
+
+	//正常运行
+	b := New(app.NewApplication())
+	routes := 0
+	closed := false
+	code := runMain(t, b,
+		WithArgs([]string{"-c", filename, "-e", "test"}),
+		WithOutput(out),
+		WithName("named"),
+		WithRoutes(func(engine *gin.Engine) {
+			routes++
+		}),
+		WithShutdown(func() {
+			closed = true
+		}),
+	)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, 1, routes)
+	assert.True(t, closed)
+	assert.Equal(t, "named", b.App.Name)
+	assert.True(t, b.App.IsTest())
+
+	//关闭失败
+	code = runMain(t, New(app.NewApplication()),
+		WithArgs([]string{"-c", filename}),
+		WithOutput(out),
+		WithShutdown(func() {
+			panic("close error")
+		}),
+	)
+	assert.Equal(t, ExitShutdownFailed, code)
+}
+
+func TestBootstrap_MainListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	filename := writeConfig(t, fmt.Sprintf("[app]\nname = \"main\"\n[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = %d\n", l.Addr().(*net.TCPAddr).Port))
+	out := &bytes.Buffer{}
+
+	b := New(app.NewApplication())
+	closed := false
+	code := runMain(t, b,
+		WithArgs([]string{"-c", filename}),
+		WithOutput(out),
+		WithShutdown(func() {
+			closed = true
+		}),
+	)
+	assert.Equal(t, ExitInitFailed, code)
+	assert.Contains(t, out.String(), "listen")
+	assert.True(t, closed, "shutdown need run after listen error")
+}
+
+func TestBootstrap_MainRoutes(t *testing.T) {
+	pid := filepath.Join(t.TempDir(), "app.pid")
+	filename := writeConfig(t, "[app]\nname = \"routes\"\npidfile = \""+pid+"\"\n"+
+		"[httpserver]\nhttp_host = \"127.0.0.1\"\nhttp_port = 0\n[mongo]\nhosts = \"127.0.0.1:27001\"\n")
+	//服务运行中
+	running := New(app.NewApplication())
+	running.App.SetCfgFile(filename)
+	assert.Nil(t, running.Init())
+	defer running.Shutdown()
+
+	assert.Nil(t, os.Setenv(RoutesEnv, "1"))
+	defer os.Unsetenv(RoutesEnv)
+	b := New(app.NewApplication())
+	var mongoErr error
+	var hs *httpserver.HttpServer
+	done := make(chan int, 1)
+	go func() {
+		done <- b.Main(WithArgs([]string{"-c", filename}), WithOutput(&bytes.Buffer{}),
+			WithInit(func() error {
+				_, mongoErr = b.App.GetMongo("")
+				hs, _ = b.App.GetHttpServer()
+				return nil
+			}),
+			WithRoutes(func(engine *gin.Engine) {
+				engine.GET("/a", func(c *gin.Context) {})
+			}))
+	}()
+	//pid文件被占用时仍然成功
+	select {
+	case code := <-done:
+		assert.Equal(t, ExitOK, code)
+	case <-time.After(2 * time.Second):
+		t.Fatal("main need return after print routes")
+	}
+	//不连接数据存储 不监听
+	assert.NotNil(t, mongoErr)
+	assert.Nil(t, hs.Listener)
+}
+
+func TestBootstrap_MainEnv(t *testing.T) {
+	filename := writeConfig(t, "[app]\nname = \"env\"\n")
+	assert.Nil(t, os.Setenv("LEGO_CONFIG", filename))
+	assert.Nil(t, os.Setenv("LEGO_ENV", "release"))
+	defer os.Unsetenv("LEGO_CONFIG")
+	defer os.Unsetenv("LEGO_ENV")
+
+	b := New(app.NewApplication())
+	assert.Equal(t, ExitOK, runMain(t, b, WithArgs(nil)))
+	assert.Equal(t, "env", b.App.Name)
+	assert.True(t, b.App.IsRelease())
+
+	//参数优先
+	b = New(app.NewApplication())
+	assert.Equal(t, ExitOK, runMain(t, b, WithArgs([]string{"-e", "prod"})))
+	assert.True(t, b.App.IsProd())
+}
@@ -0,0 +1,229 @@
+package bootstarp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jeevi-cao/lego/components/log"
+	"github.com/jeevi-cao/lego/components/mongo"
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//配置重新加载
+//	SIGUSR2 或配置文件变化时重新读取配置, 按组件订阅变化的配置节点:
+//	log    文件相关配置变化的实例重新打开日志文件, 新增实例直接创建, 级别变化即时生效
+//	mongo  连接配置变化的实例重新连接并替换, 删除的实例移除
+//	       通过 AcquireMongo 持有旧实例的使用方继续使用, 释放后关闭
+//	其余需要重启生效的配置变化时记录警告
+
+//需要重启生效的配置
+var restartKeys = []string{
+	"app.pidfile",
+	"httpserver.http_host",
+	"httpserver.http_port",
+	"httpserver.enable_https",
+	"crontab",
+	"zookeeper",
+	"remote_config",
+	"health",
+	"admin",
+}
+
+//日志组件配置
+func newLogSetting(s *logInstanceSetting) log.Setting {
+	return log.Setting{
+		Path:            s.Path,
+		FileName:        s.FileName,
+		ErrFileName:     s.ErrFileName,
+		Level:           s.Level,
+		Format:          s.Format,
+		Split:           s.Split,
+		LifeTime:        time.Duration(s.LifeTime),
+		Rotation:        time.Duration(s.Rotation),
+		ReportCaller:    true,
+		ReportHostIp:    true,
+		ReportShortFile: true,
+		RotationSize:    s.RotationSize,
+		Compress:        s.Compress,
+		MaxBackups:      s.MaxBackups,
+		MaxTotalSize:    s.MaxTotalSize,
+
+		Async:              s.Async,
+		AsyncQueueSize:     s.AsyncQueueSize,
+		AsyncPolicy:        s.AsyncPolicy,
+		AsyncFlushInterval: time.Duration(s.AsyncFlushInterval) * time.Millisecond,
+
+		Kafka: log.KafkaSetting{
+			Enable:        s.Kafka.Enable,
+			Hosts:         s.Kafka.Hosts,
+			Topic:         s.Kafka.Topic,
+			Levels:        s.Kafka.Levels,
+			Format:        s.Kafka.Format,
+			BatchSize:     s.Kafka.BatchSize,
+			FlushInterval: time.Duration(s.Kafka.FlushInterval) * time.Millisecond,
+			QueueSize:     s.Kafka.QueueSize,
+			FallbackFile:  s.Kafka.FallbackFile,
+			RequiredAcks:  s.Kafka.RequiredAcks,
+			Timeout:       s.Kafka.Timeout,
+		},
+		Syslog: log.SyslogSetting{
+			Enable:    s.Syslog.Enable,
+			Network:   s.Syslog.Network,
+			Address:   s.Syslog.Address,
+			Protocol:  s.Syslog.Protocol,
+			Facility:  s.Syslog.Facility,
+			AppName:   s.Syslog.AppName,
+			Hostname:  s.Syslog.Hostname,
+			Format:    s.Syslog.Format,
+			QueueSize: s.Syslog.QueueSize,
+		},
+		Redact: log.RedactSetting{
+			Enable:   s.Redact.Enable,
+			Keys:     s.Redact.Keys,
+			Patterns: s.Redact.Patterns,
+			Mode:     s.Redact.Mode,
+		},
+	}
+}
+
+//mongo组件配置
+func newMongoSetting(is *mongoInstanceSetting) *mongo.Setting {
+	return &mongo.Setting{
+		Uri:            is.Uri,
+		Hosts:          is.Hosts,
+		ReplSet:        is.ReplSet,
+		Username:       is.Username,
+		Password:       is.Password,
+		MaxPoolSize:    is.MaxPoolSize,
+		MinPoolSize:    is.MinPoolSize,
+		MaxIdleTime:    is.MaxIdleTime,
+		ReadPreference: is.ReadPreference,
+	}
+}
+
+//按当前配置重新打开文件配置变化的日志实例, 创建新增的实例, 并应用日志级别
+func ReloadLog(a *app.Application) error {
+	c, err := a.GetConfig()
+	if err != nil {
+		return err
+	}
+	settings, err := bindLog(c)
+	if err != nil {
+		return err
+	}
+	logs, err := a.GetAllLog()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for instance, l := range logs {
+		s, ok := settings[instance]
+		if !ok {
+			s, ok = settings[""]
+		}
+		if !ok {
+			continue
+		}
+		setting := newLogSetting(s)
+		//级别单独应用, 不需要重新打开
+		current := *l.Setting
+		current.Level = setting.Level
+		if reflect.DeepEqual(current, setting) {
+			continue
+		}
+		if err := l.Reopen(setting); err != nil {
+			failed = append(failed, fmt.Sprintf("instance:%s error:%s", instance, err.Error()))
+			continue
+		}
+		a.GetLogger("").Infof("[reload] log instance:%s reopen path:%s", instance, setting.Path)
+	}
+	for instance, s := range settings {
+		if _, ok := logs[instance]; ok || len(instance) == 0 {
+			continue
+		}
+		l, err := log.NewLog(newLogSetting(s))
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("instance:%s error:%s", instance, err.Error()))
+			continue
+		}
+		a.SetLog(instance, l)
+		a.GetLogger("").Infof("[reload] log instance:%s created", instance)
+	}
+	if err := ApplyLogLevel(a); err != nil {
+		failed = append(failed, err.Error())
+	}
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("reload log failed: %s", strings.Join(failed, "; ")))
+	}
+	return nil
+}
+
+//按当前配置重新连接配置变化的mongo实例并替换, 移除已删除的实例
+//连接失败的实例保留旧连接
+func ReloadMongo(a *app.Application) error {
+	c, err := a.GetConfig()
+	if err != nil {
+		return err
+	}
+	instances := map[string]*mongoInstanceSetting{}
+	if c.GetHandler().IsSet("mongo") {
+		if _, instances, err = bindMongo(c); err != nil {
+			return err
+		}
+	}
+	var failed []string
+	kept := make(map[*mongo.Mongo]bool)
+	for instance, is := range instances {
+		setting := newMongoSetting(is)
+		if current, err := a.GetMongo(instance); err == nil && reflect.DeepEqual(current.Setting, setting) {
+			kept[current] = true
+			continue
+		}
+		mg, err := mongo.NewMongo(setting)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("instance:%s error:%s", instance, err.Error()))
+			if current, err := a.GetMongo(instance); err == nil {
+				kept[current] = true
+			}
+			continue
+		}
+		a.ReplaceMongo(instance, mg)
+		kept[mg] = true
+		a.GetLogger("").Infof("[reload] mongo instance:%s reconnect", instance)
+	}
+	h, _ := a.GetHealth()
+	mongos, _ := a.GetAllMongo()
+	for instance, mg := range mongos {
+		if !kept[mg] {
+			a.ReplaceMongo(instance, nil)
+			a.GetLogger("").Infof("[reload] mongo instance:%s removed", instance)
+			if h != nil {
+				h.Unregister("mongo." + instance)
+			}
+		} else if h != nil {
+			//检查时获取当前实例, 只需注册新增的实例
+			registerMongoHealth(a, h, instance)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("reload mongo failed: %s", strings.Join(failed, "; ")))
+	}
+	return nil
+}
+
+//需要重启生效的配置变化时记录警告
+func watchRestartKeys(a *app.Application) {
+	c, err := a.GetConfig()
+	if err != nil {
+		return
+	}
+	for _, key := range restartKeys {
+		key := key
+		c.OnChange(key, func(old, new map[string]interface{}) {
+			a.GetLogger("").Warnf("[reload] %s changed, restart to take effect", key)
+		})
+	}
+}
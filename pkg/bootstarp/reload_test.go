@@ -0,0 +1,112 @@
+package bootstarp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+func TestReload_Components(t *testing.T) {
+	dir := t.TempDir()
+	content := func(logfile string, hosts string) string {
+		return fmt.Sprintf(`
+[app]
+name = "reload"
+[log]
+path = "%s"
+filename = "%s"
+format = "text"
+split = ".%%Y%%m%%d"
+[mongo]
+type = "multi"
+[mongo.instance.db1]
+hosts = "127.0.0.1:27001"
+[mongo.instance.db2]
+hosts = "%s"
+`, dir, logfile, hosts)
+	}
+	a := app.NewApplication()
+	filename := writeConfig(t, content("a.log", "127.0.0.1:27002"))
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+
+	l, _ := a.GetLog("")
+	db1, _ := a.GetMongo("db1")
+	db2, release, err := a.AcquireMongo("db2")
+	assert.Nil(t, err)
+	defer release()
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(content("b.log", "127.0.0.1:27003")), 0644))
+	assert.Nil(t, ReloadConfig(a))
+
+	//日志句柄不变 写入新文件
+	current, _ := a.GetLog("")
+	assert.Equal(t, l, current)
+	current.Logger.Info("after reload")
+	data, err := ioutil.ReadFile(filepath.Join(dir, "b.log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "after reload")
+
+	//只替换变化的实例, 持有的旧实例不变
+	mg, _ := a.GetMongo("db1")
+	assert.Equal(t, db1, mg)
+	mg, _ = a.GetMongo("db2")
+	assert.NotEqual(t, db2, mg)
+	assert.Equal(t, "127.0.0.1:27003", mg.Setting.Hosts)
+	assert.Equal(t, "127.0.0.1:27002", db2.Setting.Hosts)
+}
+
+func TestReload_MongoHealth(t *testing.T) {
+	content := `
+[app]
+name = "reload"
+[health]
+enable = true
+timeout = 1
+cache_ttl = 1
+[mongo]
+type = "multi"
+[mongo.instance.db1]
+hosts = "%s"
+`
+	a := app.NewApplication()
+	filename := writeConfig(t, fmt.Sprintf(content, "127.0.0.1:27001"))
+	a.SetCfgFile(filename)
+	b := New(a)
+	assert.Nil(t, b.Init())
+	defer b.Shutdown()
+
+	assert.Nil(t, ioutil.WriteFile(filename, []byte(fmt.Sprintf(content, "127.0.0.1:27002")+`
+[mongo.instance.db2]
+hosts = "127.0.0.1:27003"
+`), 0644))
+	assert.Nil(t, ReloadConfig(a))
+
+	h, _ := a.GetHealth()
+	engine := gin.New()
+	h.Routes(engine)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var report struct {
+		Checks map[string]struct {
+			Error string `json:"error"`
+		} `json:"checks"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	//检查替换后的实例, 而不是已关闭的旧实例
+	assert.Contains(t, report.Checks, "mongo.db1")
+	assert.Contains(t, report.Checks, "mongo.db2")
+	assert.NotContains(t, report.Checks["mongo.db1"].Error, "disconnected")
+}
@@ -0,0 +1,66 @@
+package bootstarp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//设置该环境变量时为路由输出模式, 用于 lego routes:
+//	Init 跳过 pid 文件 远程配置 数据存储 自定义组件等有副作用的步骤
+//	Start 只输出已注册的路由, 不启动监听, Run 输出后立即返回, Main 输出后关闭并返回 ExitOK
+const RoutesEnv = "LEGO_PRINT_ROUTES"
+
+//路由输出模式
+func isRoutesMode() bool {
+	return len(os.Getenv(RoutesEnv)) > 0
+}
+
+//路由输出模式时跳过步骤
+func skipInRoutesMode(f func(a *app.Application) error) func(a *app.Application) error {
+	return func(a *app.Application) error {
+		if isRoutesMode() {
+			return nil
+		}
+		return f(a)
+	}
+}
+
+//已注册的路由, 按路径排序
+func (b *Bootstrap) Routes() gin.RoutesInfo {
+	hs, _ := b.App.GetHttpServer()
+	if hs == nil {
+		return nil
+	}
+	routes := hs.Engine.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+//输出路由 METHOD PATH HANDLER
+func (b *Bootstrap) PrintRoutes(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range b.Routes() {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Method, r.Path, r.Handler)
+	}
+	_ = tw.Flush()
+}
+
+func Routes() gin.RoutesInfo {
+	return std.Routes()
+}
+
+func PrintRoutes(w io.Writer) {
+	std.PrintRoutes(w)
+}
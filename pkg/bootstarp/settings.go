@@ -0,0 +1,428 @@
+package bootstarp
+
+import (
+	"time"
+
+	"github.com/jeevi-cao/lego/components/config"
+	"github.com/jeevi-cao/lego/components/log"
+	"github.com/jeevi-cao/lego/components/validation"
+	"github.com/jeevi-cao/lego/pkg/app"
+	"github.com/jeevi-cao/lego/util"
+)
+
+//内置组件配置
+//单实例直接配置在组件节点下, 多实例配置 type = "multi" 并在 instance 下按实例名配置
+//	[mongo]
+//	hosts = "..."
+//或
+//	[mongo]
+//	type = "multi"
+//	[mongo.instance.db1]
+//	hosts = "..."
+
+var logLevels = []string{"trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"}
+var logFormats = []string{"json", "text", "ydLog"}
+var logAsyncPolicies = []string{log.AsyncBlock, log.AsyncDropOldest, log.AsyncDropNewest}
+var syslogNetworks = []string{"udp", "tcp", "unix", "unixgram"}
+var syslogProtocols = []string{log.SyslogRFC5424, log.SyslogRFC3164}
+var redactModes = []string{log.MaskFull, log.MaskPartial, log.MaskHash}
+var remoteFormats = []string{"toml", "json", "yaml", "yml"}
+
+//app
+type appSetting struct {
+	Name      string `mapstructure:"name"`
+	TimeZone  string `mapstructure:"time_zone"`
+	Pidfile   string `mapstructure:"pidfile"`
+	RequestId string `mapstructure:"request_id"`
+}
+
+//log
+type logSetting struct {
+	Type     string                         `mapstructure:"type"`
+	Instance map[string]*logInstanceSetting `mapstructure:"instance"`
+}
+
+type logInstanceSetting struct {
+	Path        string `mapstructure:"path"`
+	FileName    string `mapstructure:"filename"`
+	ErrFileName string `mapstructure:"errfilename"`
+	Level       string `mapstructure:"level"`
+	Format      string `mapstructure:"format"`
+	Split       string `mapstructure:"split"`
+	//保存时间 单位:小时
+	LifeTime int `mapstructure:"lifetime" valid:"Min(0)"`
+	//分割时间 单位:小时
+	Rotation int `mapstructure:"rotation" valid:"Min(0)"`
+	//按大小切割 单位:MB
+	RotationSize int64 `mapstructure:"rotation_size" valid:"Min(0)"`
+	//gzip 压缩已切割文件
+	Compress bool `mapstructure:"compress"`
+	//保留已切割文件数量
+	MaxBackups int `mapstructure:"max_backups" valid:"Min(0)"`
+	//日志文件总大小上限 单位:MB
+	MaxTotalSize int64 `mapstructure:"max_total_size" valid:"Min(0)"`
+	//异步写入文件
+	Async bool `mapstructure:"async"`
+	//异步队列长度 单位:条
+	AsyncQueueSize int `mapstructure:"async_queue_size" valid:"Min(0)"`
+	//队列满时策略 block drop_oldest drop_newest
+	AsyncPolicy string `mapstructure:"async_policy"`
+	//异步刷新间隔 单位:毫秒
+	AsyncFlushInterval int `mapstructure:"async_flush_interval" valid:"Min(0)"`
+	//发送到 kafka
+	Kafka logKafkaSetting `mapstructure:"kafka"`
+	//发送到 syslog
+	Syslog logSyslogSetting `mapstructure:"syslog"`
+	//敏感信息脱敏
+	Redact logRedactSetting `mapstructure:"redact"`
+}
+
+//日志脱敏配置
+type logRedactSetting struct {
+	Enable bool `mapstructure:"enable"`
+	//字段名匹配, 支持 * 通配
+	Keys []string `mapstructure:"keys"`
+	//值正则或内置名称 mobile email bearer
+	Patterns []string `mapstructure:"patterns"`
+	//full partial hash
+	Mode string `mapstructure:"mode"`
+}
+
+func (s *logRedactSetting) Valid(v *validation.Validation) {
+	if ok, _ := util.Contain(s.Mode, redactModes); len(s.Mode) > 0 && !ok {
+		v.SetError("Mode", "unknown redact mode:"+s.Mode)
+		return
+	}
+	if _, err := log.NewRedactHook(log.RedactSetting{Keys: s.Keys, Patterns: s.Patterns, Mode: s.Mode}); err != nil {
+		v.SetError("Patterns", err.Error())
+	}
+}
+
+//日志 syslog 配置
+type logSyslogSetting struct {
+	Enable bool `mapstructure:"enable"`
+	//udp tcp unix unixgram
+	Network string `mapstructure:"network"`
+	//host:port 或 unix socket 路径, 为空时连接本地 syslog
+	Address string `mapstructure:"address"`
+	//rfc5424 rfc3164
+	Protocol string `mapstructure:"protocol"`
+	Facility string `mapstructure:"facility"`
+	AppName  string `mapstructure:"app_name"`
+	Hostname string `mapstructure:"hostname"`
+	//json text ydLog, 默认与文件日志相同
+	Format string `mapstructure:"format"`
+	//发送队列长度 单位:条
+	QueueSize int `mapstructure:"queue_size" valid:"Min(0)"`
+}
+
+func (s *logSyslogSetting) Valid(v *validation.Validation) {
+	if ok, _ := util.Contain(s.Network, syslogNetworks); len(s.Network) > 0 && !ok {
+		v.SetError("Network", "unknown syslog network:"+s.Network)
+	}
+	if ok, _ := util.Contain(s.Protocol, syslogProtocols); len(s.Protocol) > 0 && !ok {
+		v.SetError("Protocol", "unknown syslog protocol:"+s.Protocol)
+	}
+	if _, ok := log.SyslogFacilities[s.Facility]; len(s.Facility) > 0 && !ok {
+		v.SetError("Facility", "unknown syslog facility:"+s.Facility)
+	}
+	if ok, _ := util.Contain(s.Format, logFormats); len(s.Format) > 0 && !ok {
+		v.SetError("Format", "unknown log format:"+s.Format)
+	}
+}
+
+//日志 kafka 配置
+type logKafkaSetting struct {
+	Enable bool   `mapstructure:"enable"`
+	Hosts  string `mapstructure:"hosts"`
+	Topic  string `mapstructure:"topic"`
+	//发送的级别, 默认全部
+	Levels []string `mapstructure:"levels"`
+	//json 或 ydLog, 默认与文件日志相同
+	Format string `mapstructure:"format"`
+	//批量条数
+	BatchSize int `mapstructure:"batch_size" valid:"Min(0)"`
+	//批量间隔 单位:毫秒
+	FlushInterval int `mapstructure:"flush_interval" valid:"Min(0)"`
+	//队列长度 单位:条
+	QueueSize int `mapstructure:"queue_size" valid:"Min(0)"`
+	//发送失败时写入的本地文件
+	FallbackFile string `mapstructure:"fallback_file"`
+	RequiredAcks int    `mapstructure:"required_acks" valid:"Range(-1, 1)"`
+	//发送超时 单位:秒
+	Timeout int `mapstructure:"timeout" valid:"Min(0)"`
+}
+
+func (s *logKafkaSetting) Valid(v *validation.Validation) {
+	if !s.Enable {
+		return
+	}
+	if len(s.Hosts) == 0 {
+		v.SetError("Hosts", "hosts required when kafka enable")
+	}
+	if len(s.Topic) == 0 {
+		v.SetError("Topic", "topic required when kafka enable")
+	}
+	for _, level := range s.Levels {
+		if ok, _ := util.Contain(level, logLevels); !ok {
+			v.SetError("Levels", "unknown log level:"+level)
+		}
+	}
+	if ok, _ := util.Contain(s.Format, logFormats); len(s.Format) > 0 && !ok {
+		v.SetError("Format", "unknown log format:"+s.Format)
+	}
+}
+
+func (s *logInstanceSetting) Valid(v *validation.Validation) {
+	if ok, _ := util.Contain(s.Level, logLevels); len(s.Level) > 0 && !ok {
+		v.SetError("Level", "unknown log level:"+s.Level)
+	}
+	if ok, _ := util.Contain(s.Format, logFormats); len(s.Format) > 0 && !ok {
+		v.SetError("Format", "unknown log format:"+s.Format)
+	}
+	if ok, _ := util.Contain(s.AsyncPolicy, logAsyncPolicies); len(s.AsyncPolicy) > 0 && !ok {
+		v.SetError("AsyncPolicy", "unknown log async policy:"+s.AsyncPolicy)
+	}
+	if len(s.Path) > 0 && len(s.FileName) == 0 {
+		v.SetError("FileName", "filename required when path set")
+	}
+}
+
+//crontab
+type crontabSetting struct {
+	Enable bool `mapstructure:"enable"`
+}
+
+//httpserver
+type httpServerSetting struct {
+	HttpHost    string   `mapstructure:"http_host"`
+	HttpPort    int      `mapstructure:"http_port" valid:"Range(0,65535)"`
+	EnableHttps bool     `mapstructure:"enable_https"`
+	Middleware  []string `mapstructure:"middleware"`
+}
+
+//mongo
+type mongoSetting struct {
+	Type     string                           `mapstructure:"type"`
+	Optional bool                             `mapstructure:"optional"`
+	Instance map[string]*mongoInstanceSetting `mapstructure:"instance"`
+}
+
+type mongoInstanceSetting struct {
+	Uri            string `mapstructure:"uri"`
+	Hosts          string `mapstructure:"hosts"`
+	ReplSet        string `mapstructure:"replset"`
+	Username       string `mapstructure:"username"`
+	Password       string `mapstructure:"password"`
+	MaxPoolSize    uint64 `mapstructure:"max_pool_size"`
+	MinPoolSize    uint64 `mapstructure:"min_pool_size"`
+	MaxIdleTime    int    `mapstructure:"max_idle_time" valid:"Min(0)"`
+	ReadPreference string `mapstructure:"read_preference"`
+	Optional       bool   `mapstructure:"optional"`
+}
+
+func (s *mongoInstanceSetting) Valid(v *validation.Validation) {
+	if len(s.Uri) == 0 && len(s.Hosts) == 0 {
+		v.SetError("Hosts", "hosts or uri required")
+	}
+	if s.MaxPoolSize > 0 && s.MinPoolSize > s.MaxPoolSize {
+		v.SetError("MinPoolSize", "min_pool_size greater than max_pool_size")
+	}
+}
+
+//zookeeper
+type zookeeperSetting struct {
+	Type     string                               `mapstructure:"type"`
+	Optional bool                                 `mapstructure:"optional"`
+	Instance map[string]*zookeeperInstanceSetting `mapstructure:"instance"`
+}
+
+type zookeeperInstanceSetting struct {
+	Hosts []string `mapstructure:"hosts" valid:"Required"`
+	//单位:秒 默认5秒
+	SessionTimeout int    `mapstructure:"session_timeout" valid:"Min(0)"`
+	BasePath       string `mapstructure:"base_path"`
+	Optional       bool   `mapstructure:"optional"`
+}
+
+//remote_config
+type remoteConfigSetting struct {
+	Enable bool `mapstructure:"enable"`
+	//zookeeper实例名, 单实例为空
+	Zookeeper string `mapstructure:"zookeeper"`
+	//节点路径, 相对 zookeeper base_path
+	Path string `mapstructure:"path" valid:"Required"`
+	//文档格式 toml json yaml, 为空时读取节点树
+	Format string `mapstructure:"format"`
+	//本地缓存文件, 默认为配置文件目录下 remote_config.cache.json
+	Cache string `mapstructure:"cache"`
+	//读取超时 单位:秒 默认5秒
+	Timeout int `mapstructure:"timeout" valid:"Min(0)"`
+}
+
+func (s *remoteConfigSetting) Valid(v *validation.Validation) {
+	if ok, _ := util.Contain(s.Format, remoteFormats); len(s.Format) > 0 && !ok {
+		v.SetError("Format", "unknown remote config format:"+s.Format)
+	}
+}
+
+//health
+type healthSetting struct {
+	Enable bool `mapstructure:"enable"`
+	//单位:秒
+	Timeout int `mapstructure:"timeout" valid:"Min(0)"`
+	//单位:毫秒
+	CacheTtl    int    `mapstructure:"cache_ttl" valid:"Min(0)"`
+	HealthzPath string `mapstructure:"healthz_path"`
+	ReadyzPath  string `mapstructure:"readyz_path"`
+}
+
+//admin
+type adminSetting struct {
+	Enable bool   `mapstructure:"enable"`
+	Prefix string `mapstructure:"prefix"`
+	Token  string `mapstructure:"token"`
+}
+
+//shutdown
+type shutdownSetting struct {
+	//总超时 单位:秒, 默认30
+	Timeout int `mapstructure:"timeout" valid:"Min(0)"`
+	//关闭前保持未就绪的时间 计入总超时 单位:秒
+	PreStopDelay int `mapstructure:"pre_stop_delay" valid:"Min(0)"`
+	//单个步骤超时 单位:秒, 未配置的步骤使用剩余时间
+	Steps map[string]int `mapstructure:"steps"`
+}
+
+func (s *shutdownSetting) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return time.Duration(s.Timeout) * time.Second
+	}
+	return DefaultShutdownTimeout
+}
+
+func (s *shutdownSetting) stepTimeouts() map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(s.Steps))
+	for name, t := range s.Steps {
+		if t > 0 {
+			timeouts[name] = time.Duration(t) * time.Second
+		}
+	}
+	return timeouts
+}
+
+//内置组件可通过环境变量设置的配置项, 文件中不存在时也生效
+//多实例只能覆盖文件中已有的实例
+func builtinEnvKeys() []string {
+	keys := []string{"log.type", "mongo.type", "mongo.optional", "zookeeper.type"}
+	for _, s := range []struct {
+		key string
+		out interface{}
+	}{
+		{"app", &appSetting{}},
+		{"log", &logInstanceSetting{}},
+		{"crontab", &crontabSetting{}},
+		{"httpserver", &httpServerSetting{}},
+		{"mongo", &mongoInstanceSetting{}},
+		{"zookeeper", &zookeeperInstanceSetting{}},
+		{"remote_config", &remoteConfigSetting{}},
+		{"health", &healthSetting{}},
+		{"admin", &adminSetting{}},
+		{"shutdown", &shutdownSetting{}},
+	} {
+		keys = append(keys, config.StructKeys(s.key, s.out)...)
+	}
+	return keys
+}
+
+//日志实例配置 单实例实例名为空
+func bindLog(c *config.Config) (map[string]*logInstanceSetting, error) {
+	s := &logSetting{}
+	if err := c.Bind("log", s); err != nil {
+		return nil, err
+	}
+	if app.IsMultiInstance(s.Type) {
+		return s.Instance, nil
+	}
+	single := &logInstanceSetting{}
+	if err := c.Bind("log", single); err != nil {
+		return nil, err
+	}
+	return map[string]*logInstanceSetting{"": single}, nil
+}
+
+//mongo实例配置 单实例实例名为空
+func bindMongo(c *config.Config) (*mongoSetting, map[string]*mongoInstanceSetting, error) {
+	s := &mongoSetting{}
+	if err := c.Bind("mongo", s); err != nil {
+		return nil, nil, err
+	}
+	if app.IsMultiInstance(s.Type) {
+		return s, s.Instance, nil
+	}
+	single := &mongoInstanceSetting{}
+	if err := c.Bind("mongo", single); err != nil {
+		return nil, nil, err
+	}
+	return s, map[string]*mongoInstanceSetting{"": single}, nil
+}
+
+//zookeeper实例配置 单实例实例名为空
+func bindZookeeper(c *config.Config) (*zookeeperSetting, map[string]*zookeeperInstanceSetting, error) {
+	s := &zookeeperSetting{}
+	if err := c.Bind("zookeeper", s); err != nil {
+		return nil, nil, err
+	}
+	if app.IsMultiInstance(s.Type) {
+		return s, s.Instance, nil
+	}
+	single := &zookeeperInstanceSetting{}
+	if err := c.Bind("zookeeper", single); err != nil {
+		return nil, nil, err
+	}
+	return s, map[string]*zookeeperInstanceSetting{"": single}, nil
+}
+
+//校验所有已配置的内置组件, 返回全部不合法的配置项
+func validateSettings(c *config.Config) error {
+	errs := config.BindErrors{}
+	collect := func(err error) {
+		if err == nil {
+			return
+		}
+		if e, ok := err.(config.BindErrors); ok {
+			errs = append(errs, e...)
+			return
+		}
+		errs = append(errs, &config.FieldError{Message: err.Error()})
+	}
+	v := c.GetHandler()
+	collect(c.Bind("app", &appSetting{}))
+	if v.IsSet("log") {
+		_, err := bindLog(c)
+		collect(err)
+	}
+	collect(c.Bind("crontab", &crontabSetting{}))
+	if v.IsSet("httpserver") {
+		collect(c.Bind("httpserver", &httpServerSetting{}))
+	}
+	if v.IsSet("mongo") {
+		_, _, err := bindMongo(c)
+		collect(err)
+	}
+	if v.IsSet("zookeeper") {
+		_, _, err := bindZookeeper(c)
+		collect(err)
+	}
+	if v.GetBool("remote_config.enable") {
+		collect(c.Bind("remote_config", &remoteConfigSetting{}))
+	}
+	collect(c.Bind("health", &healthSetting{}))
+	admin := &adminSetting{}
+	collect(c.Bind("admin", admin))
+	if admin.Enable && len(admin.Token) == 0 {
+		errs = append(errs, &config.FieldError{Key: "admin.token", Message: "admin need token"})
+	}
+	collect(c.Bind("shutdown", &shutdownSetting{}))
+	return errs.ErrorOrNil()
+}
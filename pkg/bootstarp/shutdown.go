@@ -1,71 +1,325 @@
 package bootstarp
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/jeevi-cao/lego/components/log"
 	"github.com/jeevi-cao/lego/pkg/app"
 )
 
-var shutdownFunc = []func(){
-	ShutdownHttpServer,
-	ShutdownCrontab,
-	ShutdownMongo,
-	ShutdownZookeeper,
-	ShutdownApp,
+//默认关闭总超时
+const DefaultShutdownTimeout = 30 * time.Second
+
+//内置关闭步骤, 依赖方先于被依赖方关闭
+func newShutdownSteps(b *Bootstrap) *steps {
+	return newSteps(
+		&Step{Name: "app", Fn: ShutdownApp},
+		&Step{Name: "pid", Deps: []string{"app"}, Fn: ShutdownPid},
+		&Step{Name: "mongo", Deps: []string{"pid"}, Fn: ShutdownMongo},
+		&Step{Name: "zookeeper", Deps: []string{"pid"}, Fn: ShutdownZookeeper},
+		&Step{Name: "components", Deps: []string{"pid"}, Fn: ShutdownComponents},
+		&Step{Name: "background", Deps: []string{"mongo", "zookeeper", "components"}, CtxFn: b.shutdownBackground},
+		&Step{Name: "crontab", Deps: []string{"mongo", "zookeeper", "components"}, CtxFn: ShutdownCrontab},
+		&Step{Name: "httpserver", Deps: []string{"mongo", "zookeeper", "components"}, CtxFn: ShutdownHttpServer},
+	)
 }
 
-func Shutdown() {
+//关闭步骤执行结果
+type StepReport struct {
+	Name string
+	Cost time.Duration
+	//步骤可用时间, 0 为不限
+	Timeout time.Duration
+	//超时或因总超时跳过
+	Exceeded bool
+	Err      error
+}
+
+//关闭报告, 按执行顺序
+type ShutdownReport []*StepReport
+
+//超时的步骤名称
+func (r ShutdownReport) Exceeded() []string {
+	names := make([]string, 0)
+	for _, st := range r {
+		if st.Exceeded {
+			names = append(names, st.Name)
+		}
+	}
+	return names
+}
+
+func (r ShutdownReport) String() string {
+	lines := make([]string, 0, len(r))
+	for _, st := range r {
+		status := "ok"
+		if st.Exceeded {
+			status = "exceeded"
+		} else if st.Err != nil {
+			status = "error"
+		}
+		lines = append(lines, fmt.Sprintf("step:%s status:%s cost:%s timeout:%s", st.Name, status, st.Cost, st.Timeout))
+	}
+	return strings.Join(lines, "\n")
+}
+
+//按依赖拓扑顺序的逆序关闭
+//标记未就绪后执行 BeforeStop 事件函数, 等待 pre_stop_delay 后关闭监听, 全部关闭后执行 AfterStop
+//总耗时不超过 shutdown.timeout, 超时的步骤不再等待, 记录在关闭报告中
+func (b *Bootstrap) Shutdown() {
+	defer b.lifecycle.Unlock()
+	b.lifecycle.Lock()
 	t1 := time.Now()
-	for _, f := range shutdownFunc {
-		f()
+	logger := b.App.GetLogger("")
+	//app 关闭后组件不可获取, 提前取出日志实例用于最后落盘
+	logs, _ := b.App.GetAllLog()
+	s := b.shutdownSetting()
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	//开始关闭 立即标记未就绪, 注销等事件函数执行期间不再接收新流量
+	if h, _ := b.App.GetHealth(); h != nil {
+		h.SetReady(false)
+	}
+	errs := ShutdownErrors{}
+	errs.merge(string(EventBeforeStop), b.runHooks(ctx, EventBeforeStop))
+	//等待负载均衡摘除流量
+	if s.PreStopDelay > 0 {
+		logger.Infof("[shutdown] pre stop delay %ds", s.PreStopDelay)
+		select {
+		case <-time.After(time.Duration(s.PreStopDelay) * time.Second):
+		case <-ctx.Done():
+		}
 	}
+	report, err := b.shutdownSteps.runReverse(ctx, b.App, s.stepTimeouts())
+	errs.merge("steps", err)
+	errs.merge(string(EventAfterStop), b.runHooks(ctx, EventAfterStop))
+	if exceeded := report.Exceeded(); len(exceeded) > 0 {
+		logger.Warnf("[shutdown] exceeded deadline steps:%s", strings.Join(exceeded, ","))
+	}
+	err = errs.ErrorOrNil()
+	if err != nil {
+		logger.Errorf("[shutdown] %s", err.Error())
+	}
+	b.mutex.Lock()
+	b.shutdownErr = err
+	b.shutdownReport = report
+	b.mutex.Unlock()
 	cost := time.Since(t1)
-	app.App.GetLogger("").Info("[shutdown] app shutdown complete! time timeline:", cost)
+	logger.Infof("[shutdown] report:\n%s", report.String())
+	logger.Info("[shutdown] app shutdown complete! time timeline:", cost)
+	//异步日志落盘
+	flushLogs(logs)
+}
+
+//等待所有日志实例异步写入的日志落盘, 失败时输出到标准错误
+func flushLogs(logs map[string]*log.Log) {
+	for instance, l := range logs {
+		if dropped := l.Dropped(); dropped > 0 {
+			l.Logger.Warnf("[shutdown] log instance:%s dropped %d entries", instance, dropped)
+		}
+		if err := l.Flush(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "[shutdown] flush log instance:%s error:%s\n", instance, err.Error())
+		}
+	}
+}
+
+//关闭配置, 配置未初始化或不合法时使用默认值
+func (b *Bootstrap) shutdownSetting() *shutdownSetting {
+	s := &shutdownSetting{}
+	c, err := b.App.GetConfig()
+	if err != nil || c == nil {
+		return s
+	}
+	if err := c.Bind("shutdown", s); err != nil {
+		b.App.GetLogger("").Errorf("[shutdown] config error:%s, use default", err.Error())
+		return &shutdownSetting{}
+	}
+	return s
+}
+
+//最近一次关闭的各步骤结果
+func (b *Bootstrap) ShutdownReport() ShutdownReport {
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	return b.shutdownReport
+}
+
+//启动后台任务, 关闭时取消 ctx 并在超时前等待所有任务返回
+//usage:
+//	Go(func(ctx context.Context) {
+//		for {
+//			select {
+//			case <-ctx.Done():
+//				return
+//			case msg := <-queue:
+//			}
+//		}
+//	})
+func (b *Bootstrap) Go(f func(ctx context.Context)) {
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				b.App.GetLogger("").Errorf("[background] panic: %v", r)
+			}
+		}()
+		f(b.backgroundCtx)
+	}()
+}
 
+//取消并等待后台任务
+func (b *Bootstrap) shutdownBackground(ctx context.Context, a *app.Application) error {
+	b.backgroundCancel()
+	done := make(chan struct{})
+	go func() {
+		b.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("background goroutines not finished")
+	}
+}
+
+//最近一次关闭的错误, 包含所有失败的步骤
+func (b *Bootstrap) ShutdownError() error {
+	defer b.mutex.Unlock()
+	b.mutex.Lock()
+	return b.shutdownErr
+}
+
+//注册关闭函数, 在当前已注册的所有步骤之前执行
+func (b *Bootstrap) RegisterShutdown(f func()) {
+	deps := b.shutdownSteps.names()
+	_ = b.shutdownSteps.add(&Step{Name: fmt.Sprintf("shutdown_%d", len(deps)), Deps: deps, Fn: wrapShutdownFunc(f)})
+}
+
+//注册带依赖的关闭步骤, deps 中的步骤在该步骤之后关闭
+//usage:
+//	RegisterShutdownStep("dao", closeDao, "mongo")
+func (b *Bootstrap) RegisterShutdownStep(name string, f func(), deps ...string) error {
+	return b.shutdownSteps.add(&Step{Name: name, Deps: deps, Fn: wrapShutdownFunc(f)})
+}
+
+//关闭函数没有返回值
+func wrapShutdownFunc(f func()) func(a *app.Application) error {
+	return func(a *app.Application) error {
+		f()
+		return nil
+	}
+}
+
+func Shutdown() {
+	std.Shutdown()
+}
+
+func Go(f func(ctx context.Context)) {
+	std.Go(f)
 }
 
 func RegisterShutdown(f func()) {
-	shutdownFunc = append(shutdownFunc, f)
+	std.RegisterShutdown(f)
+}
+
+func RegisterShutdownStep(name string, f func(), deps ...string) error {
+	return std.RegisterShutdownStep(name, f, deps...)
 }
 
-func ShutdownCrontab() {
-	cron, _ := app.App.GetCrontab()
-	if cron != nil {
-		cron.Clear()
-		cron.Stop()
-		app.App.GetLogger("").Info("[shutdown] shutdown crontab complete!")
+//停止调度并等待执行中的任务
+func ShutdownCrontab(ctx context.Context, a *app.Application) error {
+	cron, _ := a.GetCrontab()
+	if cron == nil {
+		return nil
 	}
+	err := cron.Shutdown(ctx)
+	cron.Clear()
+	if err != nil {
+		return errors.New(fmt.Sprintf("wait running jobs error:%s", err.Error()))
+	}
+	a.GetLogger("").Info("[shutdown] shutdown crontab complete!")
+	return nil
 }
 
-func ShutdownMongo() {
-	mongos, _ := app.App.GetAllMongo()
+func ShutdownMongo(a *app.Application) error {
+	mongos, _ := a.GetAllMongo()
 	if mongos == nil {
-		return
+		return nil
 	}
 	for instance, m := range mongos {
 		m.Close()
-		app.App.GetLogger("").Infof("[shutdown] shutdown mongo instance:%s complete!", instance)
+		a.GetLogger("").Infof("[shutdown] shutdown mongo instance:%s complete!", instance)
 	}
+	return nil
 }
 
-func ShutdownZookeeper() {
-	zookeepers, _ := app.App.GetAllZookeeper()
+func ShutdownZookeeper(a *app.Application) error {
+	zookeepers, _ := a.GetAllZookeeper()
 	if zookeepers == nil {
-		return
+		return nil
 	}
 	for instance, z := range zookeepers {
 		z.Stop()
-		app.App.GetLogger("").Infof("[shutdown] shutdown zookeeper instance:%s complete!", instance)
+		a.GetLogger("").Infof("[shutdown] shutdown zookeeper instance:%s complete!", instance)
+	}
+	return nil
+}
+
+func ShutdownComponents(a *app.Application) error {
+	errs := InitErrors{}
+	for _, name := range a.GetComponentNames() {
+		components, _ := a.GetAllComponent(name)
+		for instance, c := range components {
+			if err := c.Stop(); err != nil {
+				errs.Add(name, instance, err)
+				continue
+			}
+			a.GetLogger("").Infof("[shutdown] shutdown %s instance:%s complete!", name, instance)
+		}
 	}
+	return errs.ErrorOrNil()
 }
 
-func ShutdownHttpServer() {
-	hs, _ := app.App.GetHttpServer()
-	if hs != nil {
-		hs.GracefulShutdown()
+//关闭监听并等待处理中的请求
+func ShutdownHttpServer(ctx context.Context, a *app.Application) error {
+	hs, _ := a.GetHttpServer()
+	//未启动监听时无需关闭
+	if hs == nil || hs.Server == nil {
+		return nil
+	}
+	if err := hs.Shutdown(ctx); err != nil {
+		return errors.New(fmt.Sprintf("wait running requests error:%s", err.Error()))
 	}
+	a.GetLogger("").Info("[shutdown] shutdown http server complete!")
+	return nil
 }
 
-func ShutdownApp() {
-	app.App.Close()
+//所有组件关闭后删除pid文件并释放锁
+func ShutdownPid(a *app.Application) error {
+	p, _ := a.GetPidFile()
+	if p == nil {
+		return nil
+	}
+	if err := p.Remove(); err != nil {
+		return err
+	}
+	a.GetLogger("").Info("[shutdown] remove pid file complete!")
+	return nil
+}
+
+func ShutdownApp(a *app.Application) error {
+	//停止配置监听
+	if c, err := a.GetConfig(); err == nil {
+		_ = c.Close()
+	}
+	a.Close()
+	return nil
 }
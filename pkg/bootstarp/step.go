@@ -0,0 +1,278 @@
+package bootstarp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+//初始化/关闭步骤
+//Deps 为依赖的步骤名称:
+//	初始化时 依赖的步骤先执行, 互不依赖的步骤并行执行
+//	关闭时 按拓扑顺序的逆序串行执行, 即依赖方先于被依赖方关闭
+//Optional 为可选步骤, 初始化失败只记录警告 不影响启动
+//CtxFn 不为空时代替 Fn 执行, ctx 在步骤超时后取消
+type Step struct {
+	Name     string
+	Deps     []string
+	Fn       func(a *app.Application) error
+	CtxFn    func(ctx context.Context, a *app.Application) error
+	Optional bool
+}
+
+//用户注册的函数不需要Application参数
+func wrapStepFunc(f func() error) func(a *app.Application) error {
+	return func(a *app.Application) error {
+		return f()
+	}
+}
+
+//执行步骤, panic 转换为错误
+func (st *Step) run(a *app.Application) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+	return st.Fn(a)
+}
+
+//带超时执行, panic 转换为错误
+func (st *Step) runContext(ctx context.Context, a *app.Application) (err error) {
+	if st.CtxFn == nil {
+		return st.run(a)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+	return st.CtxFn(ctx, a)
+}
+
+//关闭步骤, 超过 timeout 或 ctx 结束时不再等待
+//超时的步骤继续在后台执行, 不阻塞后续步骤
+func (st *Step) shutdown(ctx context.Context, a *app.Application, timeout time.Duration) *StepReport {
+	r := &StepReport{Name: st.Name}
+	if ctx.Err() != nil {
+		r.Exceeded = true
+		r.Err = errors.New("skipped, shutdown deadline exceeded")
+		return r
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Timeout = time.Until(deadline)
+	}
+	t1 := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- st.runContext(ctx, a)
+	}()
+	select {
+	case r.Err = <-done:
+	case <-ctx.Done():
+		r.Exceeded = true
+		r.Err = errors.New(fmt.Sprintf("exceeded deadline:%s", r.Timeout))
+	}
+	r.Cost = time.Since(t1)
+	return r
+}
+
+//步骤集合
+type steps struct {
+	list  []*Step
+	mutex sync.Mutex
+}
+
+func newSteps(list ...*Step) *steps {
+	return &steps{list: list}
+}
+
+//添加步骤, 名称不可重复
+func (s *steps) add(step *Step) error {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	if len(step.Name) < 1 || step.Fn == nil {
+		return errors.New("step need name and func")
+	}
+	for _, st := range s.list {
+		if st.Name == step.Name {
+			return errors.New(fmt.Sprintf("step:%s already registered", step.Name))
+		}
+	}
+	s.list = append(s.list, step)
+	return nil
+}
+
+//当前所有步骤名称
+func (s *steps) names() []string {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	names := make([]string, 0, len(s.list))
+	for _, st := range s.list {
+		names = append(names, st.Name)
+	}
+	return names
+}
+
+//标记步骤为可选
+func (s *steps) setOptional(name string) error {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	for _, st := range s.list {
+		if st.Name == name {
+			st.Optional = true
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("step:%s not registered", name))
+}
+
+//按依赖拓扑排序, 返回分层结果, 同层内保持注册顺序且互不依赖
+//依赖不存在或存在环时返回错误
+func (s *steps) levels() ([][]*Step, error) {
+	defer s.mutex.Unlock()
+	s.mutex.Lock()
+
+	index := make(map[string]int, len(s.list))
+	for i, st := range s.list {
+		index[st.Name] = i
+	}
+	//入度 与 反向边
+	degree := make([]int, len(s.list))
+	dependents := make([][]int, len(s.list))
+	for i, st := range s.list {
+		for _, dep := range st.Deps {
+			j, ok := index[dep]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("step:%s depends on missing step:%s", st.Name, dep))
+			}
+			degree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var current []int
+	for i := range s.list {
+		if degree[i] == 0 {
+			current = append(current, i)
+		}
+	}
+	levels := make([][]*Step, 0)
+	done := 0
+	for len(current) > 0 {
+		level := make([]*Step, 0, len(current))
+		next := make([]int, 0)
+		for _, i := range current {
+			level = append(level, s.list[i])
+			for _, j := range dependents[i] {
+				degree[j]--
+				if degree[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		sort.Ints(next)
+		levels = append(levels, level)
+		done += len(current)
+		current = next
+	}
+
+	if done != len(s.list) {
+		cycle := make([]string, 0)
+		for i, st := range s.list {
+			if degree[i] > 0 {
+				cycle = append(cycle, st.Name)
+			}
+		}
+		return nil, errors.New(fmt.Sprintf("steps dependency cycle: %s", strings.Join(cycle, ",")))
+	}
+	return levels, nil
+}
+
+//初始化顺序执行: 逐层执行, 同层并行
+//必需步骤失败时 依赖它的步骤被跳过, 其余步骤继续执行, 最终返回所有失败的组件
+func (s *steps) runParallel(a *app.Application) error {
+	levels, err := s.levels()
+	if err != nil {
+		return err
+	}
+	errs := InitErrors{}
+	failed := make(map[string]bool)
+	for _, level := range levels {
+		results := make([]error, len(level))
+		skipped := make([]bool, len(level))
+		var wg sync.WaitGroup
+		for i, st := range level {
+			//依赖失败 跳过
+			for _, dep := range st.Deps {
+				if failed[dep] {
+					skipped[i] = true
+					results[i] = errors.New(fmt.Sprintf("skipped, depends on failed step:%s", dep))
+					break
+				}
+			}
+			if skipped[i] {
+				continue
+			}
+			wg.Add(1)
+			go func(i int, st *Step) {
+				defer wg.Done()
+				results[i] = st.run(a)
+			}(i, st)
+		}
+		wg.Wait()
+
+		for i, st := range level {
+			if results[i] == nil {
+				continue
+			}
+			if st.Optional && !skipped[i] {
+				a.GetLogger("").Warnf("[init] optional step:%s error:%s", st.Name, results[i].Error())
+				continue
+			}
+			failed[st.Name] = true
+			if !st.Optional {
+				errs.merge(st.Name, results[i])
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+//关闭顺序执行: 拓扑顺序的逆序 串行执行, 失败或超时的步骤不影响后续步骤
+//timeouts 为单个步骤的超时, 未配置的步骤使用 ctx 剩余时间
+func (s *steps) runReverse(ctx context.Context, a *app.Application, timeouts map[string]time.Duration) (ShutdownReport, error) {
+	levels, err := s.levels()
+	if err != nil {
+		return nil, err
+	}
+	logger := a.GetLogger("")
+	report := ShutdownReport{}
+	errs := ShutdownErrors{}
+	for i := len(levels) - 1; i >= 0; i-- {
+		for j := len(levels[i]) - 1; j >= 0; j-- {
+			st := levels[i][j]
+			r := st.shutdown(ctx, a, timeouts[st.Name])
+			report = append(report, r)
+			if r.Err != nil {
+				logger.Errorf("[shutdown] step:%s error:%s", st.Name, r.Err.Error())
+				errs = append(errs, &InitError{Component: st.Name, Err: r.Err})
+			}
+		}
+	}
+	return report, errs.ErrorOrNil()
+}
@@ -0,0 +1,132 @@
+package bootstarp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jeevi-cao/lego/pkg/app"
+)
+
+func TestSteps_Levels(t *testing.T) {
+	f := func(a *app.Application) error { return nil }
+	s := newSteps(
+		&Step{Name: "config", Fn: f},
+		&Step{Name: "log", Deps: []string{"config"}, Fn: f},
+		&Step{Name: "mongo", Deps: []string{"log"}, Fn: f},
+		&Step{Name: "zookeeper", Deps: []string{"log"}, Fn: f},
+		&Step{Name: "dao", Deps: []string{"mongo", "zookeeper"}, Fn: f},
+	)
+	levels, err := s.levels()
+	assert.Nil(t, err)
+
+	names := make([][]string, 0)
+	for _, level := range levels {
+		l := make([]string, 0)
+		for _, st := range level {
+			l = append(l, st.Name)
+		}
+		names = append(names, l)
+	}
+	assert.Equal(t, [][]string{{"config"}, {"log"}, {"mongo", "zookeeper"}, {"dao"}}, names)
+}
+
+func TestSteps_LevelsError(t *testing.T) {
+	f := func(a *app.Application) error { return nil }
+	s := newSteps(&Step{Name: "a", Deps: []string{"b"}, Fn: f})
+	_, err := s.levels()
+	assert.NotNil(t, err, "missing dependency need error")
+
+	s = newSteps(
+		&Step{Name: "a", Deps: []string{"b"}, Fn: f},
+		&Step{Name: "b", Deps: []string{"a"}, Fn: f},
+		&Step{Name: "c", Fn: f},
+	)
+	_, err = s.levels()
+	assert.NotNil(t, err, "cycle need error")
+
+	assert.NotNil(t, s.add(&Step{Name: "c", Fn: f}), "duplicate step need error")
+}
+
+func TestSteps_RunOrder(t *testing.T) {
+	var mutex sync.Mutex
+	order := make([]string, 0)
+	record := func(name string) func(a *app.Application) error {
+		return func(a *app.Application) error {
+			mutex.Lock()
+			order = append(order, name)
+			mutex.Unlock()
+			return nil
+		}
+	}
+	s := newSteps(
+		&Step{Name: "app", Fn: record("app")},
+		&Step{Name: "mongo", Deps: []string{"app"}, Fn: record("mongo")},
+		&Step{Name: "httpserver", Deps: []string{"mongo"}, Fn: record("httpserver")},
+	)
+	assert.Nil(t, s.runParallel(app.NewApplication()))
+	assert.Equal(t, []string{"app", "mongo", "httpserver"}, order)
+
+	order = order[:0]
+	_, err := s.runReverse(context.Background(), app.NewApplication(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"httpserver", "mongo", "app"}, order)
+}
+
+func TestSteps_RunError(t *testing.T) {
+	ok := func(a *app.Application) error { return nil }
+	fail := func(a *app.Application) error { return errors.New("connect error") }
+	s := newSteps(
+		&Step{Name: "log", Fn: ok},
+		&Step{Name: "mongo", Deps: []string{"log"}, Fn: fail},
+		&Step{Name: "zookeeper", Deps: []string{"log"}, Fn: fail, Optional: true},
+		&Step{Name: "dao", Deps: []string{"mongo"}, Fn: ok},
+		&Step{Name: "cache", Deps: []string{"zookeeper"}, Fn: ok},
+		&Step{Name: "panic", Fn: func(a *app.Application) error { panic("boom") }},
+	)
+	err := s.runParallel(app.NewApplication())
+	errs, is := err.(InitErrors)
+	assert.True(t, is, "error need InitErrors")
+
+	components := make([]string, 0)
+	for _, e := range errs {
+		components = append(components, e.Component)
+	}
+	//optional 步骤失败 不影响依赖它的步骤
+	assert.ElementsMatch(t, []string{"mongo", "dao", "panic"}, components)
+}
+
+func TestSteps_RunReverseTimeout(t *testing.T) {
+	ok := func(a *app.Application) error { return nil }
+	slow := func(a *app.Application) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+	var cancelled int32
+	s := newSteps(
+		&Step{Name: "app", Fn: ok},
+		&Step{Name: "mongo", Deps: []string{"app"}, Fn: slow},
+		&Step{Name: "httpserver", Deps: []string{"mongo"}, CtxFn: func(ctx context.Context, a *app.Application) error {
+			<-ctx.Done()
+			atomic.StoreInt32(&cancelled, 1)
+			return ctx.Err()
+		}},
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	t1 := time.Now()
+	report, err := s.runReverse(ctx, app.NewApplication(), map[string]time.Duration{"httpserver": 20 * time.Millisecond})
+	assert.True(t, time.Since(t1) < 500*time.Millisecond, "shutdown need bound by deadline")
+	assert.NotNil(t, err)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled), "ctx need cancel after step timeout")
+
+	//httpserver 单步超时, mongo 总超时, app 跳过
+	assert.Equal(t, []string{"httpserver", "mongo", "app"}, report.Exceeded())
+	assert.Equal(t, 3, len(err.(ShutdownErrors)))
+}